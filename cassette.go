@@ -0,0 +1,414 @@
+package flocker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrNotRecorded is returned by a replaying Cassette when no recorded
+// interaction, not replayed yet, matches the request.
+var ErrNotRecorded = errors.New("No recorded interaction matches the request")
+
+// cassetteHeaders are the response headers a Cassette keeps, the ones the
+// client uses. Everything else, cookies and the like, is dropped.
+var cassetteHeaders = []string{"Content-Type", configurationTagHeader}
+
+/*
+Cassette is an http.RoundTripper recording the traffic with the control
+service to a fixture file, or replaying it from one, so that tests can run
+without a cluster. It is plugged into a Client with WithCassette.
+
+	// Once, against a real cluster
+	cassette := flocker.NewCassette("testdata/create.json", nil)
+	c, err := flocker.NewClient(host, port, clientIP, ca, key, cert, flocker.WithCassette(cassette))
+	...
+	err = cassette.Save()
+
+	// In CI
+	cassette, err := flocker.LoadCassette("testdata/create.json")
+	c, err := flocker.NewClient("flocker.invalid", 4523, "node-1.invalid", "", "", "", flocker.WithCassette(cassette))
+
+A request is answered with the first recorded interaction not replayed yet
+with the same method, path and JSON body, once normalized. Failed requests,
+with no response, are not recorded.
+
+Nothing about TLS is recorded and host names are redacted when saving: the
+control service becomes control-service.invalid and the dataset agent nodes
+node-1.invalid, node-2.invalid, ... in the order they are listed. Replaying
+clients must then use the redacted node host as their IP.
+
+A Cassette is safe for concurrent use.
+*/
+type Cassette struct {
+	path      string
+	replaying bool
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	replayed     []bool
+	// controlHosts are the hosts the recorded requests were sent to.
+	controlHosts map[string]bool
+}
+
+// Interaction is a request to the control service along with its response,
+// as saved by a Cassette.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request saved by a Cassette.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	// Path includes the query string, if any.
+	Path string `json:"path"`
+	Body string `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response saved by a Cassette.
+type RecordedResponse struct {
+	StatusCode int                 `json:"status_code"`
+	Header     map[string][]string `json:"header,omitempty"`
+	Body       string              `json:"body,omitempty"`
+}
+
+type cassetteFile struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// NewCassette returns a Cassette recording the requests sent through
+// transport, see Save. A nil transport is the one of the Client the Cassette
+// is given to, or http.DefaultTransport.
+func NewCassette(path string, transport http.RoundTripper) *Cassette {
+	return &Cassette{
+		path:         path,
+		transport:    transport,
+		controlHosts: map[string]bool{},
+	}
+}
+
+// LoadCassette returns a Cassette replaying the interactions saved at path.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f cassetteFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("Invalid cassette %s: %w", path, err)
+	}
+	return &Cassette{
+		path:         path,
+		replaying:    true,
+		interactions: f.Interactions,
+		replayed:     make([]bool, len(f.Interactions)),
+	}, nil
+}
+
+// WithCassette makes the client send its requests through cassette. A
+// replaying cassette needs no certificates, NewClient does not load them.
+func WithCassette(cassette *Cassette) Option {
+	return func(c *Client) {
+		c.cassette = cassette
+	}
+}
+
+// wrap returns a copy of hc sending its requests through the cassette.
+func (c *Cassette) wrap(hc *http.Client) *http.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wrapped := *hc
+	if c.transport == nil && !c.replaying {
+		c.transport = hc.Transport
+	}
+	wrapped.Transport = c
+	return &wrapped
+}
+
+// RoundTrip records the request and its response, or answers it with a
+// recorded one when replaying.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := RecordedRequest{
+		Method: req.Method,
+		Path:   req.URL.RequestURI(),
+		Body:   normalizeJSON(body),
+	}
+
+	if c.replaying {
+		return c.replay(req, recorded)
+	}
+	return c.record(req, recorded)
+}
+
+func (c *Cassette) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, interaction := range c.interactions {
+		if c.replayed[i] || interaction.Request != recorded {
+			continue
+		}
+		c.replayed[i] = true
+
+		r := interaction.Response
+		header := http.Header{}
+		for k, v := range r.Header {
+			header[k] = append([]string{}, v...)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+			StatusCode:    r.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewBufferString(r.Body)),
+			ContentLength: int64(len(r.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, recorded.Method, recorded.Path)
+}
+
+func (c *Cassette) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	c.mu.Lock()
+	transport := c.transport
+	c.mu.Unlock()
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     map[string][]string{},
+			Body:       string(body),
+		},
+	}
+	for _, k := range cassetteHeaders {
+		if v := resp.Header.Values(k); len(v) > 0 {
+			interaction.Response.Header[k] = append([]string{}, v...)
+		}
+	}
+
+	c.mu.Lock()
+	c.interactions = append(c.interactions, interaction)
+	c.controlHosts[req.URL.Hostname()] = true
+	c.mu.Unlock()
+	return resp, nil
+}
+
+// Save writes the recorded interactions to the path of the cassette, with
+// host names redacted.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	redactions := c.redactions()
+	f := cassetteFile{Interactions: make([]Interaction, len(c.interactions))}
+	for i, interaction := range c.interactions {
+		interaction.Request.Body = redactJSON(interaction.Request.Body, redactions)
+		interaction.Response.Body = redactJSON(interaction.Response.Body, redactions)
+		f.Interactions[i] = interaction
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, append(b, '\n'), 0644)
+}
+
+// redactions maps the host names seen in the recorded traffic to their
+// placeholder. It must be called with mu held.
+func (c *Cassette) redactions() map[string]string {
+	redactions := map[string]string{}
+	for _, interaction := range c.interactions {
+		if interaction.Request.Method != "GET" || interaction.Request.Path != "/v1/state/nodes" {
+			continue
+		}
+		var nodes []NodeState
+		json.Unmarshal([]byte(interaction.Response.Body), &nodes)
+		for _, n := range nodes {
+			if _, ok := redactions[n.Host]; !ok && n.Host != "" {
+				redactions[n.Host] = fmt.Sprintf("node-%d.invalid", len(redactions)+1)
+			}
+		}
+	}
+
+	var hosts []string
+	for host := range c.controlHosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		// A node running the control service keeps its node placeholder
+		if _, ok := redactions[host]; !ok {
+			redactions[host] = "control-service.invalid"
+		}
+	}
+	return redactions
+}
+
+// readRequestBody returns the body of req, leaving it readable again.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// normalizeJSON returns body with its keys sorted and without insignificant
+// white space, so equal JSON documents compare equal. Anything else is kept
+// as it is.
+func normalizeJSON(body []byte) string {
+	v, ok := decodeJSON(string(body))
+	if !ok {
+		return string(body)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return string(body)
+	}
+	return string(b)
+}
+
+// redactJSON replaces the host names found in redactions within the string
+// values of body, see redactString. A body which is not JSON is kept as it is.
+func redactJSON(body string, redactions map[string]string) string {
+	v, ok := decodeJSON(body)
+	if !ok {
+		return body
+	}
+	b, err := json.Marshal(redactValue(v, redactions))
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+func decodeJSON(s string) (interface{}, bool) {
+	if len(bytes.TrimSpace([]byte(s))) == 0 {
+		return nil, false
+	}
+	d := json.NewDecoder(bytes.NewBufferString(s))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil || d.More() {
+		return nil, false
+	}
+	return v, true
+}
+
+func redactValue(v interface{}, redactions map[string]string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return redactString(v, redactions)
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i], redactions)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = redactValue(v[k], redactions)
+		}
+	}
+	return v
+}
+
+// redactString replaces the host names found in redactions within s, such as
+// a node IP in an error message or in a URL. Longer hosts are replaced first
+// and only whole host names are: 10.0.0.1 is not replaced within 10.0.0.12.
+func redactString(s string, redactions map[string]string) string {
+	hosts := make([]string, 0, len(redactions))
+	for host := range redactions {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if len(hosts[i]) != len(hosts[j]) {
+			return len(hosts[i]) > len(hosts[j])
+		}
+		return hosts[i] < hosts[j]
+	})
+
+	for _, host := range hosts {
+		var b strings.Builder
+		rest := s
+		for {
+			i := strings.Index(rest, host)
+			if i < 0 {
+				b.WriteString(rest)
+				break
+			}
+			end := i + len(host)
+			b.WriteString(rest[:i])
+			if isHostBoundary(rest[:i], rest[end:]) {
+				b.WriteString(redactions[host])
+			} else {
+				b.WriteString(host)
+			}
+			rest = rest[end:]
+		}
+		s = b.String()
+	}
+	return s
+}
+
+// isHostBoundary says whether a host name found between before and after is
+// a whole one, not part of a longer name or address.
+func isHostBoundary(before, after string) bool {
+	if before != "" && isHostChar(before[len(before)-1], true) {
+		return false
+	}
+	if after == "" {
+		return true
+	}
+	// A dot ending a sentence is fine, one followed by more of the name is not
+	if after[0] == '.' {
+		return len(after) == 1 || !isHostChar(after[1], false)
+	}
+	return !isHostChar(after[0], false)
+}
+
+// isHostChar says whether c can be part of a host name, dots included when
+// withDot is set.
+func isHostChar(c byte, withDot bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+		return true
+	case c == '.':
+		return withDot
+	}
+	return false
+}
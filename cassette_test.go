@@ -0,0 +1,177 @@
+package flocker
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClusterHQ/flocker-go/flockertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	fake := flockertest.NewServer(flockertest.WithNodes(
+		flockertest.Node{UUID: "n1", Host: "10.0.0.1"},
+		flockertest.Node{UUID: "n2", Host: "10.0.0.2"},
+	))
+	defer fake.Close()
+
+	recorder := NewCassette(path, nil)
+	c, err := NewClient(fake.Host(), fake.Port(), "10.0.0.1", "", "", "",
+		WithHTTPClient(fake.Client()),
+		WithCassette(recorder),
+		WithPollInterval(time.Millisecond),
+	)
+	assert.NoError(err)
+
+	created, err := c.CreateDataset(&CreateDatasetOptions{Metadata: map[string]string{"name": "db"}})
+	assert.NoError(err)
+	nodes, err := c.ListNodes()
+	assert.NoError(err)
+	assert.NoError(recorder.Save())
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	for _, secret := range []string{"10.0.0.1", "10.0.0.2", fake.Host(), strconv.Itoa(fake.Port()), "CERTIFICATE"} {
+		assert.NotContains(string(b), secret)
+	}
+
+	// Replaying needs no server and no certificates
+	fake.Close()
+	player, err := LoadCassette(path)
+	assert.NoError(err)
+	c, err = NewClient("flocker.invalid", 4523, "node-1.invalid", "", "", "",
+		WithCassette(player),
+		WithPollInterval(time.Millisecond),
+	)
+	assert.NoError(err)
+
+	replayed, err := c.CreateDataset(&CreateDatasetOptions{Metadata: map[string]string{"name": "db"}})
+	assert.NoError(err)
+	assert.Equal(created, replayed)
+
+	replayedNodes, err := c.ListNodes()
+	assert.NoError(err)
+	assert.Equal([]NodeState{{UUID: "n1", Host: "node-1.invalid"}, {UUID: "n2", Host: "node-2.invalid"}}, replayedNodes)
+	assert.Len(nodes, 2)
+
+	// Every interaction was replayed
+	_, err = c.ListNodes()
+	assert.True(errors.Is(err, ErrNotRecorded))
+}
+
+func TestCassetteMatchesNormalizedJSON(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	assert.NoError(ioutil.WriteFile(path, []byte(`{"interactions": [
+		{
+			"request": {"method": "POST", "path": "/v1/configuration/datasets", "body": "{\"metadata\":{\"name\":\"db\"},\"primary\":\"n1\"}"},
+			"response": {"status_code": 201, "header": {"Content-Type": ["application/json"]}, "body": "{\"dataset_id\": \"d1\"}"}
+		}
+	]}`), 0644))
+
+	post := func(c *Cassette, body string) (*http.Response, error) {
+		req, err := http.NewRequest("POST", "https://flocker.invalid:4523/v1/configuration/datasets", bytes.NewBufferString(body))
+		assert.NoError(err)
+		return c.RoundTrip(req)
+	}
+
+	c, err := LoadCassette(path)
+	assert.NoError(err)
+
+	_, err = post(c, `{"primary": "n2", "metadata": {"name": "db"}}`)
+	assert.True(errors.Is(err, ErrNotRecorded))
+
+	resp, err := post(c, `{ "primary" : "n1",
+		"metadata": {"name": "db"} }`)
+	assert.NoError(err)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+	assert.Equal("application/json", resp.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(err)
+	assert.Equal(`{"dataset_id": "d1"}`, string(body))
+
+	_, err = LoadCassette(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(err)
+}
+
+func TestCassetteRedactsHostsInMessages(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/state/nodes" {
+			w.Write([]byte(`[{"host": "10.0.0.1", "uuid": "n1"}, {"host": "10.0.0.12", "uuid": "n2"}]`))
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"description": "Dataset is in use on 10.0.0.1, see https://10.0.0.12:4523/v1/state/nodes."}`))
+	}))
+	defer ts.Close()
+
+	recorder := NewCassette(path, nil)
+	hc := &http.Client{Transport: recorder}
+	for _, url := range []string{ts.URL + "/v1/state/nodes", ts.URL + "/v1/configuration/datasets/d1"} {
+		resp, err := hc.Get(url)
+		assert.NoError(err)
+		resp.Body.Close()
+	}
+	assert.NoError(recorder.Save())
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.NotContains(string(b), "10.0.0.1")
+	assert.Contains(string(b), `Dataset is in use on node-1.invalid, see https://node-2.invalid:4523/v1/state/nodes.`)
+}
+
+func TestCassetteReplayMissNotRetried(t *testing.T) {
+	assert := assert.New(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	assert.NoError(ioutil.WriteFile(path, []byte(`{"interactions": []}`), 0644))
+
+	player, err := LoadCassette(path)
+	assert.NoError(err)
+	c, err := NewClient("flocker.invalid", 4523, "node-1.invalid", "", "", "",
+		WithCassette(player),
+		WithClock(&fakeClock{}),
+	)
+	assert.NoError(err)
+
+	_, err = c.ListNodes()
+	assert.True(errors.Is(err, ErrNotRecorded))
+	assert.False(strings.Contains(err.Error(), "attempts"), err.Error())
+}
+
+func TestRedactString(t *testing.T) {
+	assert := assert.New(t)
+	redactions := map[string]string{
+		"10.0.0.1":  "node-1.invalid",
+		"10.0.0.10": "node-2.invalid",
+		"flocker":   "control-service.invalid",
+	}
+
+	assert.Equal("node-1.invalid", redactString("10.0.0.1", redactions))
+	assert.Equal("on node-1.invalid and node-2.invalid.", redactString("on 10.0.0.1 and 10.0.0.10.", redactions))
+	assert.Equal("https://control-service.invalid:4523/v1", redactString("https://flocker:4523/v1", redactions))
+	assert.Equal("10.0.0.12 110.0.0.1 flocker-go", redactString("10.0.0.12 110.0.0.1 flocker-go", redactions))
+}
+
+func TestNormalizeJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(`{"a":[1,2.50],"b":"x"}`, normalizeJSON([]byte(` {"b": "x", "a": [1, 2.50]} `)))
+	assert.Equal("", normalizeJSON(nil))
+	assert.Equal("not json", normalizeJSON([]byte("not json")))
+	assert.Equal(`{} {}`, normalizeJSON([]byte(`{} {}`)))
+}
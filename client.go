@@ -2,6 +2,7 @@ package flocker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,25 +12,15 @@ import (
 )
 
 // From https://github.com/ClusterHQ/flocker-docker-plugin/blob/master/flockerdockerplugin/adapter.py#L18
-const defaultVolumeSize = json.Number("107374182400")
+const defaultVolumeSize = 100 * GiB
 
 var (
-	// A volume can take a long time to be available, if we don't want
-	// Kubernetes to wait forever we need to stop trying after some time, that
-	// time is defined here
-	timeoutWaitingForVolume = 2 * time.Minute
-	tickerWaitingForVolume  = 5 * time.Second
-
-	errStateNotFound         = errors.New("State not found by Dataset ID")
-	errConfigurationNotFound = errors.New("Configuration not found by Name")
-
 	errFlockerControlServiceHost = errors.New("The volume config must have a key CONTROL_SERVICE_HOST defined in the OtherAttributes field")
 	errFlockerControlServicePort = errors.New("The volume config must have a key CONTROL_SERVICE_PORT defined in the OtherAttributes field")
 
-	errVolumeAlreadyExists = errors.New("The volume already exists")
-	errVolumeDoesNotExist  = errors.New("The volume does not exist")
+	errVolumeDoesNotExist = errors.New("The volume does not exist")
 
-	errUpdatingDataset = errors.New("It was impossible to update the dataset")
+	errWaitTimeout = &kindError{"Timeout waiting for the control service", ErrTimeout}
 )
 
 // Clientable exposes the needed methods to implement your own Flocker Client.
@@ -44,6 +35,31 @@ type Clientable interface {
 	ListNodes() (nodes []NodeState, err error)
 
 	UpdatePrimaryForDataset(primaryUUID, datasetID string) (*DatasetState, error)
+
+	AcquireLease(datasetID, nodeUUID string, expires time.Duration) (*Lease, error)
+	ReleaseLease(datasetID string) error
+	ListLeases() ([]Lease, error)
+}
+
+// ExtendedClientable exposes the rest of the dataset methods of Client. It is
+// optional, implementations of Clientable need not provide it: callers check
+// for it with a type assertion.
+type ExtendedClientable interface {
+	Clientable
+
+	DeleteDatasetAndWait(datasetID string, opts *DeleteOptions) error
+	FindDuplicateNames() (map[string][]string, error)
+
+	ListDatasetConfigurations() ([]DatasetConfiguration, error)
+	ListDatasetStates() ([]DatasetState, error)
+	ListDatasets() ([]Dataset, error)
+	GetDataset(datasetID string) (*Dataset, error)
+	FindDatasets(selector Selector) ([]Dataset, error)
+
+	UpdateDatasetMetadata(datasetID string, patch MetadataPatch) (*DatasetConfiguration, error)
+	ResizeDataset(datasetID string, newSize Size) (*DatasetState, error)
+	MoveDataset(datasetID, newPrimary string, opts *MoveOptions) (*DatasetState, error)
+	EnsureDataset(name string, options *CreateDatasetOptions) (*DatasetState, error)
 }
 
 // Client is a default Flocker Client.
@@ -57,37 +73,83 @@ type Client struct {
 
 	clientIP string
 
-	maximumSize json.Number
+	maximumSize Size
+	rounding    RoundingPolicy
+
+	waitTimeout time.Duration
+	backoff     Backoff
+	clock       Clock
+
+	retry RetryPolicy
+
+	versions *versionCache
+
+	idNamespace string
+
+	cassette *Cassette
 }
 
-var _ Clientable = &Client{}
+var _ ExtendedClientable = &Client{}
 
 // NewClient creates a wrapper over http.Client to communicate with the flocker control service.
-func NewClient(host string, port int, clientIP string, caCertPath, keyPath, certPath string) (*Client, error) {
-	client, err := newTLSClient(caCertPath, keyPath, certPath)
-	if err != nil {
-		return nil, err
-	}
-
-	return &Client{
-		Client:      client,
+// The given options are applied in order over the defaults. The certificates
+// are not loaded when the http.Client is given with WithHTTPClient, or when
+// replaying a Cassette.
+func NewClient(host string, port int, clientIP string, caCertPath, keyPath, certPath string, opts ...Option) (*Client, error) {
+	c := &Client{
 		schema:      "https",
 		host:        host,
 		port:        port,
 		version:     "v1",
 		maximumSize: defaultVolumeSize,
+		rounding:    RoundUpTo(KiB),
 		clientIP:    clientIP,
-	}, nil
+		waitTimeout: defaultWaitTimeout,
+		backoff:     ConstantBackoff(defaultPollInterval),
+		clock:       realClock{},
+		retry:       DefaultRetryPolicy,
+		versions:    &versionCache{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.Client == nil && c.cassette != nil && c.cassette.replaying {
+		c.Client = &http.Client{}
+	}
+	if c.Client == nil {
+		client, err := newTLSClient(caCertPath, keyPath, certPath)
+		if err != nil {
+			return nil, err
+		}
+		c.Client = client
+	}
+	if c.cassette != nil {
+		c.Client = c.cassette.wrap(c.Client)
+	}
+
+	if c.idNamespace != "" {
+		if _, err := parseUUID(c.idNamespace); err != nil {
+			return nil, fmt.Errorf("Invalid namespace for deterministic IDs: %s", err)
+		}
+	}
+
+	return c, nil
 }
 
 /*
 request do a request using the http.Client embedded to the control service
-and returns the response or an error in case it happens.
+and returns the response or an error in case it happens. The request is
+bound to ctx, so cancelling it aborts the call.
+
+Failed attempts are retried as the client's RetryPolicy says. When the
+attempts run out on a retryable status code an *APIError is returned instead
+of the response.
 
 Note: you will need to deal with the response body call to Close if you
 don't want to deal with problems later.
 */
-func (c Client) request(method, url string, payload interface{}) (*http.Response, error) {
+func (c Client) request(ctx context.Context, method, url string, payload interface{}, opts ...WriteOption) (*http.Response, error) {
 	var (
 		b   []byte
 		err error
@@ -100,29 +162,96 @@ func (c Client) request(method, url string, payload interface{}) (*http.Response
 		}
 	}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(b))
-	if err != nil {
-		return nil, err
+	// An older control service would silently ignore the precondition
+	tag := newWriteOptions(opts).ifMatches
+	if tag != "" && method != "GET" {
+		if err := c.require(ctx, CapabilityConfigurationTags); err != nil {
+			return nil, err
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	// REMEMBER TO CLOSE THE BODY IN THE OUTSIDE FUNCTION
-	return c.Do(req)
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(b))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if tag != "" && method != "GET" {
+			req.Header.Set(ifConfigurationMatchesHeader, tag)
+		}
+
+		// REMEMBER TO CLOSE THE BODY IN THE OUTSIDE FUNCTION
+		resp, err := c.Do(req)
+		if c.retry.MaxAttempts < 2 || ctx.Err() != nil || !c.retry.retryable(req, resp, err) {
+			return resp, err
+		}
+		if attempt >= c.retry.MaxAttempts {
+			return nil, attemptsExhausted(attempt, req, resp, err)
+		}
+
+		if resp != nil {
+			discard(resp)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.clock.After(c.retry.delay(attempt)):
+		}
+	}
 }
 
 // post performs a post request with the indicated payload
-func (c Client) post(url string, payload interface{}) (*http.Response, error) {
-	return c.request("POST", url, payload)
+func (c Client) post(ctx context.Context, url string, payload interface{}, opts ...WriteOption) (*http.Response, error) {
+	return c.request(ctx, "POST", url, payload, opts...)
 }
 
 // delete performs a delete request with the indicated payload
-func (c Client) delete(url string, payload interface{}) (*http.Response, error) {
-	return c.request("DELETE", url, payload)
+func (c Client) delete(ctx context.Context, url string, payload interface{}, opts ...WriteOption) (*http.Response, error) {
+	return c.request(ctx, "DELETE", url, payload, opts...)
 }
 
 // get performs a get request
-func (c Client) get(url string) (*http.Response, error) {
-	return c.request("GET", url, nil)
+func (c Client) get(ctx context.Context, url string) (*http.Response, error) {
+	return c.request(ctx, "GET", url, nil)
+}
+
+/*
+waitFor calls check until it reports done or fails, sleeping between calls as
+the client's Backoff says. It gives up with errWaitTimeout once the client's
+wait timeout expires, or with ctx.Err() if ctx is done first.
+*/
+func (c Client) waitFor(ctx context.Context, check func() (done bool, err error)) error {
+	deadline := c.clock.Now().Add(c.waitTimeout)
+
+	for attempt := 1; ; attempt++ {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+
+		remaining := deadline.Sub(c.clock.Now())
+		if remaining <= 0 {
+			return errWaitTimeout
+		}
+		delay := c.backoff.Delay(attempt)
+		if delay > remaining {
+			delay = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.clock.After(delay):
+		}
+	}
+}
+
+// roundSize applies the client's RoundingPolicy to size.
+func (c Client) roundSize(size Size) Size {
+	if c.rounding == nil {
+		return size
+	}
+	return c.rounding(size)
 }
 
 // getURL returns a full URI to the control service
@@ -131,33 +260,35 @@ func (c Client) getURL(path string) string {
 }
 
 type configurationPayload struct {
-	Deleted     bool            `json:"deleted"`
-	Primary     string          `json:"primary"`
-	DatasetID   string          `json:"dataset_id,omitempty"`
-	MaximumSize json.Number     `json:"maximum_size,omitempty"`
-	Metadata    metadataPayload `json:"metadata,omitempty"`
+	Deleted     bool              `json:"deleted"`
+	Primary     string            `json:"primary"`
+	DatasetID   string            `json:"dataset_id,omitempty"`
+	MaximumSize Size              `json:"maximum_size,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
 type CreateDatasetOptions struct {
 	Primary     string            `json:"primary"`
 	DatasetID   string            `json:"dataset_id,omitempty"`
-	MaximumSize int64             `json:"maximum_size,omitempty"`
+	MaximumSize Size              `json:"maximum_size,omitempty"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
-type metadataPayload struct {
-	Name string `json:"name,omitempty"`
+// DatasetConfiguration is the configuration of a dataset, that is, the state
+// the control service is trying to bring it to.
+type DatasetConfiguration struct {
+	DatasetID   string            `json:"dataset_id"`
+	Primary     string            `json:"primary"`
+	Deleted     bool              `json:"deleted"`
+	MaximumSize Size              `json:"maximum_size,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
 }
 
 type DatasetState struct {
-	Path        string      `json:"path"`
-	DatasetID   string      `json:"dataset_id"`
-	Primary     string      `json:"primary,omitempty"`
-	MaximumSize json.Number `json:"maximum_size,omitempty"`
-}
-
-type datasetStatePayload struct {
-	*DatasetState
+	Path        string `json:"path"`
+	DatasetID   string `json:"dataset_id"`
+	Primary     string `json:"primary,omitempty"`
+	MaximumSize Size   `json:"maximum_size,omitempty"`
 }
 
 type NodeState struct {
@@ -166,29 +297,34 @@ type NodeState struct {
 }
 
 // findIDInConfigurationsPayload returns the datasetID if it was found in the
-// configurations payload, otherwise it will return an error.
+// configurations payload, otherwise it will return an error, see
+// findConfigurationByName.
 func (c Client) findIDInConfigurationsPayload(body io.ReadCloser, name string) (datasetID string, err error) {
-	var configurations []configurationPayload
-	if err = json.NewDecoder(body).Decode(&configurations); err == nil {
-		for _, r := range configurations {
-			if r.Metadata.Name == name {
-				return r.DatasetID, nil
-			}
-		}
-		return "", errConfigurationNotFound
+	var configurations []DatasetConfiguration
+	if err = json.NewDecoder(body).Decode(&configurations); err != nil {
+		return "", err
 	}
-	return "", err
+	configuration, err := c.findConfigurationByName(configurations, name)
+	if err != nil {
+		return "", err
+	}
+	return configuration.DatasetID, nil
 }
 
 // ListNodes returns a list of dataset agent nodes from Flocker Control Service
 func (c *Client) ListNodes() (nodes []NodeState, err error) {
-	resp, err := c.get(c.getURL("state/nodes"))
+	return c.ListNodesContext(context.Background())
+}
+
+// ListNodesContext is like ListNodes but the request is bound to ctx.
+func (c *Client) ListNodesContext(ctx context.Context) (nodes []NodeState, err error) {
+	resp, err := c.get(ctx, c.getURL("state/nodes"))
 	if err != nil {
 		return []NodeState{}, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return []NodeState{}, fmt.Errorf("Expected: {1,2}xx listing nodes, got: %d", resp.StatusCode)
+		return []NodeState{}, newAPIError(resp)
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&nodes)
@@ -201,7 +337,12 @@ func (c *Client) ListNodes() (nodes []NodeState, err error) {
 // GetPrimaryUUID returns the UUID of the primary Flocker Control Service for
 // the given host.
 func (c Client) GetPrimaryUUID() (uuid string, err error) {
-	states, err := c.ListNodes()
+	return c.GetPrimaryUUIDContext(context.Background())
+}
+
+// GetPrimaryUUIDContext is like GetPrimaryUUID but the request is bound to ctx.
+func (c Client) GetPrimaryUUIDContext(ctx context.Context) (uuid string, err error) {
+	states, err := c.ListNodesContext(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -216,15 +357,21 @@ func (c Client) GetPrimaryUUID() (uuid string, err error) {
 
 // DeleteDataset performs a delete request to the given datasetID
 func (c *Client) DeleteDataset(datasetID string) error {
+	return c.DeleteDatasetContext(context.Background(), datasetID)
+}
+
+// DeleteDatasetContext is like DeleteDataset but the request is bound to ctx
+// and made as opts say.
+func (c *Client) DeleteDatasetContext(ctx context.Context, datasetID string, opts ...WriteOption) error {
 	url := c.getURL(fmt.Sprintf("configuration/datasets/%s", datasetID))
-	resp, err := c.delete(url, nil)
+	resp, err := c.delete(ctx, url, nil, opts...)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("Expected: {1,2}xx deleting the dataset %s, got: %d", datasetID, resp.StatusCode)
+		return newAPIError(resp)
 	}
 
 	return nil
@@ -233,23 +380,74 @@ func (c *Client) DeleteDataset(datasetID string) error {
 // GetDatasetState performs a get request to get the state of the given datasetID, if
 // something goes wrong or the datasetID was not found it returns an error.
 func (c Client) GetDatasetState(datasetID string) (*DatasetState, error) {
-	resp, err := c.get(c.getURL("state/datasets"))
+	return c.GetDatasetStateContext(context.Background(), datasetID)
+}
+
+// GetDatasetStateContext is like GetDatasetState but the request is bound to
+// ctx.
+func (c Client) GetDatasetStateContext(ctx context.Context, datasetID string) (*DatasetState, error) {
+	states, err := c.ListDatasetStatesContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var states []datasetStatePayload
-	if err = json.NewDecoder(resp.Body).Decode(&states); err == nil {
-		for _, s := range states {
-			if s.DatasetID == datasetID {
-				return s.DatasetState, nil
-			}
+	for _, s := range states {
+		if s.DatasetID == datasetID {
+			return &s, nil
 		}
-		return nil, errStateNotFound
 	}
+	return nil, ErrStateNotFound
+}
+
+// ListDatasetStates returns the current state of every dataset, as reported
+// by the dataset agents. Deleted datasets and datasets still being created
+// have no state.
+func (c Client) ListDatasetStates() ([]DatasetState, error) {
+	return c.ListDatasetStatesContext(context.Background())
+}
+
+// ListDatasetStatesContext is like ListDatasetStates but the request is bound
+// to ctx.
+func (c Client) ListDatasetStatesContext(ctx context.Context) ([]DatasetState, error) {
+	resp, err := c.get(ctx, c.getURL("state/datasets"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, newAPIError(resp)
+	}
+
+	var states []DatasetState
+	if err := json.NewDecoder(resp.Body).Decode(&states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
 
-	return nil, err
+// ListDatasetConfigurations returns the configuration of every dataset,
+// including the deleted ones.
+func (c Client) ListDatasetConfigurations() ([]DatasetConfiguration, error) {
+	return c.ListDatasetConfigurationsContext(context.Background())
+}
+
+// ListDatasetConfigurationsContext is like ListDatasetConfigurations but the
+// request is bound to ctx.
+func (c Client) ListDatasetConfigurationsContext(ctx context.Context) ([]DatasetConfiguration, error) {
+	configurations, _, err := c.listConfigurations(ctx)
+	return configurations, err
+}
+
+// ListDatasetConfigurationsWithTag is like ListDatasetConfigurations but also
+// returns the tag of the configuration, see IfConfigurationMatches.
+func (c Client) ListDatasetConfigurationsWithTag() ([]DatasetConfiguration, string, error) {
+	return c.ListDatasetConfigurationsWithTagContext(context.Background())
+}
+
+// ListDatasetConfigurationsWithTagContext is like
+// ListDatasetConfigurationsWithTag but the request is bound to ctx.
+func (c Client) ListDatasetConfigurationsWithTagContext(ctx context.Context) ([]DatasetConfiguration, string, error) {
+	return c.listConfigurations(ctx)
 }
 
 /*
@@ -263,73 +461,120 @@ This process is a little bit complex but follows this flow:
 3. If it didn't previously exist, wait for it to be ready
 */
 func (c *Client) CreateDataset(options *CreateDatasetOptions) (datasetState *DatasetState, err error) {
+	return c.CreateDatasetContext(context.Background(), options)
+}
+
+/*
+CreateDatasetContext is like CreateDataset but every request, as well as the
+wait for the dataset to be ready, is bound to ctx. The creation is made as
+opts say.
+
+If ctx is done while waiting, the dataset is left in place (it may still
+become ready) and an error wrapping ctx.Err() is returned. If it is not ready
+in time it is deleted. If waiting fails for another reason, e.g. the control
+service kept failing, the dataset is left in place too and a
+*CreateDatasetError with its ID is returned.
+*/
+func (c *Client) CreateDatasetContext(ctx context.Context, options *CreateDatasetOptions, opts ...WriteOption) (datasetState *DatasetState, err error) {
+	datasetID, err := c.postDataset(ctx, options, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.waitCreated(ctx, datasetID)
+}
+
+// waitCreated waits until the dataset just created is ready, deleting it if it
+// is not ready in time.
+func (c *Client) waitCreated(ctx context.Context, datasetID string) (datasetState *DatasetState, err error) {
+	// 3) Wait until the dataset is ready for usage. In case it never gets
+	// ready the wait times out and the dataset is deleted
+	var s *DatasetState
+	err = c.waitFor(ctx, func() (bool, error) {
+		var errState error
+		s, errState = c.GetDatasetStateContext(ctx, datasetID)
+		if errState == ErrStateNotFound {
+			return false, nil
+		}
+		return errState == nil, errState
+	})
+
+	switch {
+	case err == nil:
+		return s, nil
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("Flocker API wait cancelled during dataset creation (datasetID %s): %w", datasetID, ctx.Err())
+	case err == errWaitTimeout:
+		strErrDel := c.deleteCreated(datasetID)
+		return nil, fmt.Errorf("%w during dataset creation (datasetID %s): %w%s", ErrTimeout, datasetID, ErrStateNotFound, strErrDel)
+	default:
+		// The dataset may be fine, only the control service is failing
+		return nil, &CreateDatasetError{DatasetID: datasetID, Err: err}
+	}
+}
+
+// postDataset adds the dataset to the configuration, filling the defaults in
+// options, and returns its ID.
+func (c *Client) postDataset(ctx context.Context, options *CreateDatasetOptions, opts ...WriteOption) (datasetID string, err error) {
 	// 1) Find the primary Flocker UUID
 	// Note: it could be cached, but doing this query we health check it
 	if options.Primary == "" {
-		options.Primary, err = c.GetPrimaryUUID()
+		options.Primary, err = c.GetPrimaryUUIDContext(ctx)
 		if err != nil {
-			return nil, err
+			return "", err
 		}
 	}
 
 	if options.MaximumSize == 0 {
-		options.MaximumSize, _ = c.maximumSize.Int64()
+		options.MaximumSize = c.maximumSize
+	}
+	if options.DatasetID == "" {
+		options.DatasetID = c.datasetIDForName(options.Metadata["name"])
 	}
+	options.MaximumSize = c.roundSize(options.MaximumSize)
 
-	resp, err := c.post(c.getURL("configuration/datasets"), options)
+	resp, err := c.post(ctx, c.getURL("configuration/datasets"), options, opts...)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	// 2) Return if the dataset was previously created
 	if resp.StatusCode == http.StatusConflict {
-		return nil, errVolumeAlreadyExists
+		return "", ErrVolumeAlreadyExists
 	}
 
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Expected: {1,2}xx creating the volume, got: %d", resp.StatusCode)
+		return "", newAPIError(resp)
 	}
 
 	var p configurationPayload
 	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
-		return nil, err
+		return "", err
 	}
+	return p.DatasetID, nil
+}
 
-	// 3) Wait until the dataset is ready for usage. In case it never gets
-	// ready there is a timeoutChan that will return an error
-	timeoutChan := time.NewTimer(timeoutWaitingForVolume).C
-	tickChan := time.NewTicker(tickerWaitingForVolume).C
-
-	for {
-		var strErrDel string
-		s, err := c.GetDatasetState(p.DatasetID)
-		if err == nil {
-			return s, nil
-		} else if err != errStateNotFound {
-			errDel := c.DeleteDataset(p.DatasetID)
-			if errDel != nil {
-				strErrDel = fmt.Sprintf(", deletion of dataset failed with %s", errDel)
-			}
-			return nil, fmt.Errorf("Flocker API error during dataset creation (datasetID %s): %s%s", p.DatasetID, err, strErrDel)
-		}
-
-		select {
-		case <-timeoutChan:
-			errDel := c.DeleteDataset(p.DatasetID)
-			if errDel != nil {
-				strErrDel = fmt.Sprintf(", deletion of dataset failed with %s", errDel)
-			}
-			return nil, fmt.Errorf("Flocker API timeout during dataset creation (datasetID %s): %s%s", p.DatasetID, err, strErrDel)
-		case <-tickChan:
-			break
-		}
+// deleteCreated deletes a dataset which did not get ready, returning what to
+// add to the error message if it failed. Cleaning up has its own context, the
+// one of the creation may be about to expire.
+func (c *Client) deleteCreated(datasetID string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+	if errDel := c.DeleteDatasetContext(ctx, datasetID); errDel != nil {
+		return fmt.Sprintf(", deletion of dataset failed with %s", errDel)
 	}
+	return ""
 }
 
 // UpdatePrimaryForDataset will update the Primary for the given dataset
 // returning the current DatasetState.
 func (c Client) UpdatePrimaryForDataset(newPrimaryUUID, datasetID string) (*DatasetState, error) {
+	return c.UpdatePrimaryForDatasetContext(context.Background(), newPrimaryUUID, datasetID)
+}
+
+// UpdatePrimaryForDatasetContext is like UpdatePrimaryForDataset but the
+// request is bound to ctx and made as opts say.
+func (c Client) UpdatePrimaryForDatasetContext(ctx context.Context, newPrimaryUUID, datasetID string, opts ...WriteOption) (*DatasetState, error) {
 	payload := struct {
 		Primary string `json:"primary"`
 	}{
@@ -337,14 +582,14 @@ func (c Client) UpdatePrimaryForDataset(newPrimaryUUID, datasetID string) (*Data
 	}
 
 	url := c.getURL(fmt.Sprintf("configuration/datasets/%s", datasetID))
-	resp, err := c.post(url, payload)
+	resp, err := c.post(ctx, url, payload, opts...)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return nil, errUpdatingDataset
+		return nil, newAPIError(resp)
 	}
 
 	var s DatasetState
@@ -356,21 +601,76 @@ func (c Client) UpdatePrimaryForDataset(newPrimaryUUID, datasetID string) (*Data
 }
 
 // GetDatasetID will return the DatasetID found for the given metadata name.
+// Deleted datasets are ignored, and if more than one dataset has the name an
+// error matching ErrAmbiguousName is returned.
 func (c Client) GetDatasetID(metaName string) (datasetID string, err error) {
-	resp, err := c.get(c.getURL("configuration/datasets"))
+	return c.GetDatasetIDContext(context.Background(), metaName)
+}
+
+// GetDatasetIDContext is like GetDatasetID but the request is bound to ctx.
+func (c Client) GetDatasetIDContext(ctx context.Context, metaName string) (datasetID string, err error) {
+	datasetID, _, err = c.GetDatasetIDWithTagContext(ctx, metaName)
+	return datasetID, err
+}
+
+// GetDatasetIDWithTag is like GetDatasetID but also returns the tag of the
+// configuration the ID was found in, see IfConfigurationMatches.
+func (c Client) GetDatasetIDWithTag(metaName string) (datasetID, tag string, err error) {
+	return c.GetDatasetIDWithTagContext(context.Background(), metaName)
+}
+
+// GetDatasetIDWithTagContext is like GetDatasetIDWithTag but the request is
+// bound to ctx.
+func (c Client) GetDatasetIDWithTagContext(ctx context.Context, metaName string) (datasetID, tag string, err error) {
+	configurations, tag, err := c.listConfigurations(ctx)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	defer resp.Body.Close()
 
-	var configurations []configurationPayload
-	if err = json.NewDecoder(resp.Body).Decode(&configurations); err == nil {
-		for _, c := range configurations {
-			if c.Metadata.Name == metaName && c.Deleted == false {
-				return c.DatasetID, nil
-			}
+	configuration, err := c.findConfigurationByName(configurations, metaName)
+	if err != nil {
+		return "", "", err
+	}
+	return configuration.DatasetID, tag, nil
+}
+
+// findConfigurationByName returns the configuration, not deleted, with the
+// given metadata name. With deterministic IDs the one with the ID derived from
+// the name is preferred, otherwise an *AmbiguousNameError is returned when
+// more than one has the name.
+func (c Client) findConfigurationByName(configurations []DatasetConfiguration, name string) (*DatasetConfiguration, error) {
+	if id := c.datasetIDForName(name); id != "" {
+		if configuration := findConfiguration(configurations, id); configuration != nil && configuration.Metadata["name"] == name {
+			return configuration, nil
 		}
-		return "", errConfigurationNotFound
 	}
-	return "", err
+
+	ids := datasetIDsByName(configurations)[name]
+	switch len(ids) {
+	case 0:
+		return nil, ErrConfigurationNotFound
+	case 1:
+		return findConfiguration(configurations, ids[0]), nil
+	default:
+		return nil, &AmbiguousNameError{Name: name, DatasetIDs: ids}
+	}
+}
+
+// listConfigurations returns every dataset configuration along with the tag
+// of the configuration they were read from.
+func (c Client) listConfigurations(ctx context.Context) ([]DatasetConfiguration, string, error) {
+	resp, err := c.get(ctx, c.getURL("configuration/datasets"))
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, "", newAPIError(resp)
+	}
+
+	var configurations []DatasetConfiguration
+	if err = json.NewDecoder(resp.Body).Decode(&configurations); err != nil {
+		return nil, "", err
+	}
+	return configurations, resp.Header.Get(configurationTagHeader), nil
 }
@@ -2,7 +2,9 @@ package flocker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -10,6 +12,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,9 +22,7 @@ import (
 func TestMaximumSizeIs1024Multiple(t *testing.T) {
 	assert := assert.New(t)
 
-	n, err := strconv.Atoi(string(defaultVolumeSize))
-	assert.NoError(err)
-	assert.Equal(0, n%1024)
+	assert.Equal(Size(0), defaultVolumeSize%1024)
 }
 
 func TestPost(t *testing.T) {
@@ -47,7 +48,7 @@ func TestPost(t *testing.T) {
 
 	c := Client{Client: &http.Client{}}
 
-	resp, err := c.post(ts.URL, payload{expectedPayload})
+	resp, err := c.post(context.Background(), ts.URL, payload{expectedPayload})
 	assert.NoError(err)
 	assert.Equal(expectedStatusCode, resp.StatusCode)
 }
@@ -66,7 +67,7 @@ func TestGet(t *testing.T) {
 
 	c := Client{Client: &http.Client{}}
 
-	resp, err := c.get(ts.URL)
+	resp, err := c.get(context.Background(), ts.URL)
 	assert.NoError(err)
 	assert.Equal(expectedStatusCode, resp.StatusCode)
 }
@@ -94,7 +95,8 @@ func TestFindIDInConfigurationsPayload(t *testing.T) {
 	id, err = c.findIDInConfigurationsPayload(
 		ioutil.NopCloser(bytes.NewBufferString(payload)), "it will not be found",
 	)
-	assert.Equal(errConfigurationNotFound, err)
+	assert.Equal(ErrConfigurationNotFound, err)
+	assert.True(errors.Is(err, ErrNotFound))
 
 	id, err = c.findIDInConfigurationsPayload(
 		ioutil.NopCloser(bytes.NewBufferString("invalid { json")), "",
@@ -257,6 +259,15 @@ func TestDeleteDatasetNotExisting(t *testing.T) {
 
 	err = c.DeleteDataset("uuid2")
 	assert.Error(err)
+	assert.True(errors.Is(err, ErrNotFound))
+
+	var apiErr *APIError
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal("DELETE", apiErr.Method)
+		assert.Equal("/v1/configuration/datasets/uuid2", apiErr.Path)
+		assert.Equal(404, apiErr.StatusCode)
+		assert.Equal("Dataset not found.", apiErr.Description)
+	}
 }
 
 func TestHappyPathCreateDatasetFromNonExistent(t *testing.T) {
@@ -289,7 +300,7 @@ func TestHappyPathCreateDatasetFromNonExistent(t *testing.T) {
 			assert.NoError(err)
 			assert.Equal(expectedPrimary, c.Primary)
 			assert.Equal(defaultVolumeSize, c.MaximumSize)
-			assert.Equal(expectedDatasetName, c.Metadata.Name)
+			assert.Equal(expectedDatasetName, c.Metadata["name"])
 
 			w.Write([]byte(fmt.Sprintf(`{"dataset_id": "%s"}`, expectedDatasetID)))
 		case 3:
@@ -306,9 +317,7 @@ func TestHappyPathCreateDatasetFromNonExistent(t *testing.T) {
 	host, port, err := getHostAndPortFromTestServer(ts)
 	assert.NoError(err)
 
-	c := newFlockerTestClient(host, port)
-
-	tickerWaitingForVolume = 1 * time.Millisecond // TODO: this is overriding globally
+	c := newFlockerTestClient(host, port, WithPollInterval(1*time.Millisecond))
 
 	s, err := c.CreateDataset(&CreateDatasetOptions{
 		Metadata: map[string]string{
@@ -353,7 +362,8 @@ func TestCreateDatasetThatAlreadyExists(t *testing.T) {
 			"name": datasetName,
 		},
 	})
-	assert.Equal(errVolumeAlreadyExists, err)
+	assert.Equal(ErrVolumeAlreadyExists, err)
+	assert.True(errors.Is(err, ErrConflict))
 }
 
 func TestCreateDatasetThatTimesoutServerSide(t *testing.T) {
@@ -363,10 +373,6 @@ func TestCreateDatasetThatTimesoutServerSide(t *testing.T) {
 		expectedDatasetID = "uuid-1"
 	)
 
-	// reduce timeouts for testing
-	tickerWaitingForVolume = 1 * time.Microsecond
-	timeoutWaitingForVolume = 1 * time.Millisecond
-
 	assert := assert.New(t)
 	var numCalls int
 	var deleted bool
@@ -406,7 +412,7 @@ func TestCreateDatasetThatTimesoutServerSide(t *testing.T) {
 	host, port, err := getHostAndPortFromTestServer(ts)
 	assert.NoError(err)
 
-	c := newFlockerTestClient(host, port)
+	c := newFlockerTestClient(host, port, WithPollInterval(1*time.Microsecond), WithWaitTimeout(1*time.Millisecond))
 
 	_, err = c.CreateDataset(&CreateDatasetOptions{
 		Metadata: map[string]string{
@@ -418,6 +424,8 @@ func TestCreateDatasetThatTimesoutServerSide(t *testing.T) {
 	assert.True(numCalls > 3, fmt.Sprintf("Not enough retries getting dataset state: %d", numCalls))
 	assert.True(deleted, "Failed dataset was not cleaned up afterwards")
 	assert.Equal("Flocker API timeout during dataset creation (datasetID uuid-1): State not found by Dataset ID", err.Error())
+	assert.True(errors.Is(err, ErrTimeout))
+	assert.True(errors.Is(err, ErrStateNotFound))
 }
 
 func TestCreateDatasetThatTimesoutServerSideFailedDelete(t *testing.T) {
@@ -427,10 +435,6 @@ func TestCreateDatasetThatTimesoutServerSideFailedDelete(t *testing.T) {
 		expectedDatasetID = "uuid-1"
 	)
 
-	// reduce timeouts for testing
-	tickerWaitingForVolume = 1 * time.Microsecond
-	timeoutWaitingForVolume = 1 * time.Millisecond
-
 	assert := assert.New(t)
 	var numCalls int
 	var deleted bool
@@ -465,7 +469,7 @@ func TestCreateDatasetThatTimesoutServerSideFailedDelete(t *testing.T) {
 	host, port, err := getHostAndPortFromTestServer(ts)
 	assert.NoError(err)
 
-	c := newFlockerTestClient(host, port)
+	c := newFlockerTestClient(host, port, WithPollInterval(1*time.Microsecond), WithWaitTimeout(1*time.Millisecond))
 
 	_, err = c.CreateDataset(&CreateDatasetOptions{
 		Metadata: map[string]string{
@@ -476,7 +480,190 @@ func TestCreateDatasetThatTimesoutServerSideFailedDelete(t *testing.T) {
 
 	assert.True(numCalls > 3, fmt.Sprintf("Not enough retries getting dataset state: %d", numCalls))
 	assert.True(deleted, "Failed dataset was not cleaned up afterwards")
-	assert.Equal("Flocker API timeout during dataset creation (datasetID uuid-1): State not found by Dataset ID, deletion of dataset failed with Expected: {1,2}xx deleting the dataset uuid-1, got: 500", err.Error())
+	assert.Equal("Flocker API timeout during dataset creation (datasetID uuid-1): State not found by Dataset ID, deletion of dataset failed with Flocker API error: DELETE /v1/configuration/datasets/uuid-1 returned 500: unexpected error", err.Error())
+	assert.True(errors.Is(err, ErrTimeout))
+}
+
+func TestCreateDatasetContextCancelledWhileWaiting(t *testing.T) {
+	const (
+		datasetName       = "dir"
+		expectedPrimary   = "A-B-C-D"
+		expectedDatasetID = "uuid-1"
+	)
+
+	assert := assert.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var deleted bool
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			w.Write([]byte(fmt.Sprintf(`{"dataset_id": "%s"}`, expectedDatasetID)))
+		case r.URL.Path == "/v1/state/datasets":
+			cancel()
+			w.Write([]byte(`[]`))
+		case r.Method == "DELETE":
+			deleted = true
+		default:
+			t.Errorf("Received unexpected call '%s' to '%s'", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	host, port, err := getHostAndPortFromTestServer(ts)
+	assert.NoError(err)
+
+	c := newFlockerTestClient(host, port, WithPollInterval(1*time.Millisecond), WithWaitTimeout(1*time.Minute))
+
+	_, err = c.CreateDatasetContext(ctx, &CreateDatasetOptions{
+		Primary: expectedPrimary,
+		Metadata: map[string]string{
+			"name": datasetName,
+		},
+	})
+
+	assert.Error(err)
+	assert.True(errors.Is(err, context.Canceled), "error wraps context.Canceled")
+	assert.False(deleted, "Dataset was deleted after cancellation")
+}
+
+func TestCreateDatasetKeptOnWaitError(t *testing.T) {
+	assert := assert.New(t)
+	var deleted bool
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			w.Write([]byte(`{"dataset_id": "uuid-1"}`))
+		case r.URL.Path == "/v1/state/datasets":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`unexpected error`))
+		case r.Method == "DELETE":
+			deleted = true
+		default:
+			t.Errorf("Received unexpected call '%s' to '%s'", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	host, port, err := getHostAndPortFromTestServer(ts)
+	assert.NoError(err)
+
+	c := newFlockerTestClient(host, port, WithPollInterval(1*time.Millisecond))
+
+	_, err = c.CreateDataset(&CreateDatasetOptions{Primary: "A-B-C-D"})
+
+	var createErr *CreateDatasetError
+	assert.True(errors.As(err, &createErr))
+	assert.Equal("uuid-1", createErr.DatasetID)
+	assert.Equal("Flocker API error during dataset creation (datasetID uuid-1): Flocker API error: GET /v1/state/datasets returned 500: unexpected error", err.Error())
+	assert.False(deleted, "Dataset was deleted after a failed wait")
+}
+
+func TestCreateDatasetWaitIsConfiguredPerClient(t *testing.T) {
+	assert := assert.New(t)
+	var statePolls int
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			w.Write([]byte(`{"dataset_id": "uuid-1"}`))
+		case r.Method == "GET":
+			statePolls++
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer ts.Close()
+
+	host, port, err := getHostAndPortFromTestServer(ts)
+	assert.NoError(err)
+
+	clock := &fakeClock{}
+	c := newFlockerTestClient(host, port,
+		WithClock(clock),
+		WithPollInterval(time.Second),
+		WithWaitTimeout(3*time.Second),
+	)
+	other := newFlockerTestClient(host, port)
+
+	_, err = c.CreateDataset(&CreateDatasetOptions{Primary: "A-B-C-D"})
+	assert.Error(err)
+	assert.Equal(4, statePolls, "polls at 0s, 1s, 2s and 3s")
+	assert.Equal(3*time.Second, clock.Now().Sub(time.Time{}))
+	assert.Equal(defaultWaitTimeout, other.waitTimeout, "other clients keep their own wait policy")
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	assert := assert.New(t)
+
+	b := ExponentialBackoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}
+	assert.Equal(1*time.Second, b.Delay(1))
+	assert.Equal(2*time.Second, b.Delay(2))
+	assert.Equal(4*time.Second, b.Delay(3))
+	assert.Equal(5*time.Second, b.Delay(4))
+	assert.Equal(5*time.Second, b.Delay(100))
+}
+
+func TestListDatasetConfigurations(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("GET", r.Method)
+		assert.Equal("/v1/configuration/datasets", r.URL.Path)
+		w.Write([]byte(`[
+			{"dataset_id": "d1", "primary": "p1", "deleted": false, "maximum_size": 1073741824, "metadata": {"name": "one", "owner": "alice"}},
+			{"dataset_id": "d2", "primary": "p2", "deleted": true, "maximum_size": null}
+		]`))
+	}))
+	defer ts.Close()
+
+	host, port, err := getHostAndPortFromTestServer(ts)
+	assert.NoError(err)
+
+	c := newFlockerTestClient(host, port)
+
+	configurations, err := c.ListDatasetConfigurations()
+	assert.NoError(err)
+	assert.Equal([]DatasetConfiguration{
+		{
+			DatasetID:   "d1",
+			Primary:     "p1",
+			MaximumSize: 1073741824,
+			Metadata:    map[string]string{"name": "one", "owner": "alice"},
+		},
+		{
+			DatasetID: "d2",
+			Primary:   "p2",
+			Deleted:   true,
+		},
+	}, configurations)
+}
+
+func TestListDatasetStates(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("GET", r.Method)
+		assert.Equal("/v1/state/datasets", r.URL.Path)
+		w.Write([]byte(`[
+			{"dataset_id": "d1", "primary": "p1", "maximum_size": 1073741824, "path": "/flocker/d1"},
+			{"dataset_id": "d2", "primary": "p2"}
+		]`))
+	}))
+	defer ts.Close()
+
+	host, port, err := getHostAndPortFromTestServer(ts)
+	assert.NoError(err)
+
+	c := newFlockerTestClient(host, port)
+
+	states, err := c.ListDatasetStates()
+	assert.NoError(err)
+	assert.Equal([]DatasetState{
+		{DatasetID: "d1", Primary: "p1", MaximumSize: GiB, Path: "/flocker/d1"},
+		{DatasetID: "d2", Primary: "p2"},
+	}, states)
 }
 
 func TestUpdatePrimaryForDataset(t *testing.T) {
@@ -513,14 +700,57 @@ func TestUpdatePrimaryForDataset(t *testing.T) {
 	assert.NotEqual("", s.Path)
 }
 
-func newFlockerTestClient(host string, port int) *Client {
-	return &Client{
+// fakeClock is a Clock whose time only moves when someone waits on it, so
+// waits complete instantly and always poll the same number of times.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- f.now
+	return ch
+}
+
+func newFlockerTestClient(host string, port int, opts ...Option) *Client {
+	c := &Client{
 		Client:      &http.Client{},
 		host:        host,
 		port:        port,
 		version:     "v1",
 		schema:      "http",
 		maximumSize: defaultVolumeSize,
+		rounding:    RoundUpTo(KiB),
 		clientIP:    "127.0.0.1",
+		waitTimeout: defaultWaitTimeout,
+		backoff:     ConstantBackoff(defaultPollInterval),
+		clock:       realClock{},
+		retry:       DefaultRetryPolicy,
+		versions:    &versionCache{},
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+// newHandlerTestClient starts a test server answering with handler and
+// returns a client of it, along with the function closing the server.
+func newHandlerTestClient(assert *assert.Assertions, handler http.Handler, opts ...Option) (*Client, func()) {
+	ts := httptest.NewServer(handler)
+
+	host, port, err := getHostAndPortFromTestServer(ts)
+	assert.NoError(err)
+
+	return newFlockerTestClient(host, port, opts...), ts.Close
 }
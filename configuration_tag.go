@@ -0,0 +1,95 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	// configurationTagHeader carries the tag of the configuration a response
+	// was built from.
+	configurationTagHeader = "X-Configuration-Tag"
+	// ifConfigurationMatchesHeader makes the control service refuse a change
+	// unless its configuration still has the given tag.
+	ifConfigurationMatchesHeader = "X-If-Configuration-Matches"
+)
+
+// ErrPreconditionFailed is matched by errors.Is when a change was refused
+// because the configuration changed since its tag was read.
+var ErrPreconditionFailed = errors.New("Precondition failed")
+
+// WriteOption changes how a change to the configuration is made, it is given
+// to CreateDatasetContext, UpdatePrimaryForDatasetContext,
+// UpdateDatasetMetadataContext and DeleteDatasetContext.
+type WriteOption func(*writeOptions)
+
+type writeOptions struct {
+	// ifMatches is the tag the configuration must still have, if any.
+	ifMatches string
+}
+
+func newWriteOptions(opts []WriteOption) writeOptions {
+	var w writeOptions
+	for _, opt := range opts {
+		opt(&w)
+	}
+	return w
+}
+
+/*
+IfConfigurationMatches makes the change be refused unless the configuration
+of the control service still has the given tag, see GetConfigurationTag and
+the ...WithTag reads. Refused changes fail with an error matching
+ErrPreconditionFailed.
+
+An empty tag sets no precondition.
+*/
+func IfConfigurationMatches(tag string) WriteOption {
+	return func(w *writeOptions) {
+		w.ifMatches = tag
+	}
+}
+
+// GetConfigurationTag returns the tag of the current configuration of the
+// control service, it changes every time the configuration does.
+func (c Client) GetConfigurationTag() (string, error) {
+	return c.GetConfigurationTagContext(context.Background())
+}
+
+// GetConfigurationTagContext is like GetConfigurationTag but the request is
+// bound to ctx.
+func (c Client) GetConfigurationTagContext(ctx context.Context) (string, error) {
+	_, tag, err := c.listConfigurations(ctx)
+	return tag, err
+}
+
+/*
+RetryOnStaleConfiguration calls change with an IfConfigurationMatches option
+for the current configuration tag, which change gives to the writes it makes.
+If change fails because the configuration changed in between, the tag is
+read again and change retried, up to attempts times. change is always called
+at least once, attempts below 1 count as 1.
+
+change must read whatever it bases its decision on again every time it is
+called.
+*/
+func (c Client) RetryOnStaleConfiguration(ctx context.Context, attempts int, change func(ctx context.Context, ifMatches WriteOption) error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		var tag string
+		tag, err = c.GetConfigurationTagContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = change(ctx, IfConfigurationMatches(tag))
+		if !errors.Is(err, ErrPreconditionFailed) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// taggedServer serves the dataset configurations with a tag that changes
+// every time one is updated, refusing stale updates.
+type taggedServer struct {
+	tag     int
+	updates int
+	// interfere changes the configuration behind the client's back before
+	// the next updates.
+	interfere int
+}
+
+func (s *taggedServer) currentTag() string {
+	return string(rune('a' + s.tag))
+}
+
+func (s *taggedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v1/version":
+		w.Write([]byte(`{"flocker": "1.15.0"}`))
+	case r.Method == "GET":
+		w.Header().Set(configurationTagHeader, s.currentTag())
+		w.Write([]byte(`[]`))
+	case r.Method == "POST":
+		if s.interfere > 0 {
+			s.interfere--
+			s.tag++
+		}
+		if tag := r.Header.Get(ifConfigurationMatchesHeader); tag != "" && tag != s.currentTag() {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{"description": "Configuration tag does not match."}`))
+			return
+		}
+		s.updates++
+		s.tag++
+		w.Write([]byte(`{"dataset_id": "datasetID"}`))
+	}
+}
+
+func TestGetConfigurationTag(t *testing.T) {
+	assert := assert.New(t)
+	s := &taggedServer{}
+
+	c, done := newHandlerTestClient(assert, s)
+	defer done()
+
+	tag, err := c.GetConfigurationTag()
+	assert.NoError(err)
+	assert.Equal("a", tag)
+}
+
+func TestIfConfigurationMatches(t *testing.T) {
+	assert := assert.New(t)
+	s := &taggedServer{}
+
+	c, done := newHandlerTestClient(assert, s)
+	defer done()
+
+	ctx := context.Background()
+	_, err := c.UpdatePrimaryForDatasetContext(ctx, "node1", "datasetID", IfConfigurationMatches("a"))
+	assert.NoError(err)
+
+	_, err = c.UpdatePrimaryForDatasetContext(ctx, "node2", "datasetID", IfConfigurationMatches("a"))
+	assert.True(errors.Is(err, ErrPreconditionFailed), "stale tag is refused")
+	assert.Equal(1, s.updates)
+
+	_, err = c.UpdatePrimaryForDatasetContext(ctx, "node2", "datasetID", IfConfigurationMatches(""))
+	assert.NoError(err, "empty tag sets no precondition")
+
+	_, err = c.UpdatePrimaryForDatasetContext(ctx, "node1", "datasetID")
+	assert.NoError(err, "writes without the option are not conditional")
+}
+
+func TestIfConfigurationMatchesUnsupported(t *testing.T) {
+	assert := assert.New(t)
+	var posted bool
+
+	c, done := newHandlerTestClient(assert, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/version" {
+			w.Write([]byte(`{"flocker": "1.2.0"}`))
+			return
+		}
+		posted = true
+	}))
+	defer done()
+
+	err := c.DeleteDatasetContext(context.Background(), "datasetID", IfConfigurationMatches("a"))
+	assert.True(errors.Is(err, ErrUnsupported))
+	assert.False(posted, "the change is not sent without its precondition")
+}
+
+func TestRetryOnStaleConfiguration(t *testing.T) {
+	assert := assert.New(t)
+	s := &taggedServer{interfere: 2}
+
+	c, done := newHandlerTestClient(assert, s)
+	defer done()
+
+	var calls int
+	err := c.RetryOnStaleConfiguration(context.Background(), 3, func(ctx context.Context, ifMatches WriteOption) error {
+		calls++
+		_, err := c.UpdatePrimaryForDatasetContext(ctx, "node1", "datasetID", ifMatches)
+		return err
+	})
+	assert.NoError(err)
+	assert.Equal(3, calls)
+	assert.Equal(1, s.updates)
+
+	s.interfere = 5
+	err = c.RetryOnStaleConfiguration(context.Background(), 2, func(ctx context.Context, ifMatches WriteOption) error {
+		_, err := c.UpdatePrimaryForDatasetContext(ctx, "node1", "datasetID", ifMatches)
+		return err
+	})
+	assert.True(errors.Is(err, ErrPreconditionFailed))
+}
+
+func TestRetryOnStaleConfigurationAttempts(t *testing.T) {
+	assert := assert.New(t)
+	s := &taggedServer{}
+
+	c, done := newHandlerTestClient(assert, s)
+	defer done()
+
+	for _, attempts := range []int{0, -1} {
+		var calls int
+		err := c.RetryOnStaleConfiguration(context.Background(), attempts, func(ctx context.Context, ifMatches WriteOption) error {
+			calls++
+			_, err := c.UpdatePrimaryForDatasetContext(ctx, "node1", "datasetID", ifMatches)
+			return err
+		})
+		assert.NoError(err)
+		assert.Equal(1, calls, "the change is made once")
+	}
+	assert.Equal(2, s.updates)
+}
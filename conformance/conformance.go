@@ -0,0 +1,319 @@
+/*
+Package conformance checks that an implementation of flocker.Clientable
+behaves like flocker.Client: which calls wait for the control service to
+converge, how conflicts and lookups by name work and which errors match which
+sentinel.
+
+	func TestMyClient(t *testing.T) {
+		conformance.RunClientableConformance(t, func(t *testing.T) conformance.Target {
+			return conformance.Target{Client: newMyClient(t), Nodes: []string{"n1", "n2"}}
+		})
+	}
+
+The suite creates, moves and deletes datasets, it can also run against a real
+cluster but should not be pointed at one in use.
+*/
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	flocker "github.com/ClusterHQ/flocker-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// Target is the Clientable under test along with what the suite needs to
+// know about the cluster behind it.
+type Target struct {
+	// Client is the implementation under test. The tests which need the
+	// methods of flocker.ExtendedClientable are skipped if it does not
+	// implement it.
+	Client flocker.Clientable
+	// Nodes are the UUIDs of at least two dataset agent nodes. The first one
+	// must be the primary GetPrimaryUUID returns.
+	Nodes []string
+}
+
+// Factory returns a fresh Target for every test of the suite, it can use
+// t.Cleanup to release it.
+type Factory func(t *testing.T) Target
+
+// RunClientableConformance runs the conformance suite, every test as a
+// subtest of t.
+func RunClientableConformance(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(*testing.T, Target)
+	}{
+		{"CreateDataset", testCreateDataset},
+		{"CreateDatasetConflict", testCreateDatasetConflict},
+		{"Lookup", testLookup},
+		{"LookupAmbiguousName", testLookupAmbiguousName},
+		{"List", testList},
+		{"Move", testMove},
+		{"Delete", testDelete},
+		{"Leases", testLeases},
+	}
+
+	for _, tt := range tests {
+		test := tt.test
+		t.Run(tt.name, func(t *testing.T) {
+			target := factory(t)
+			if len(target.Nodes) < 2 {
+				t.Fatalf("The target needs at least 2 nodes, it has %d", len(target.Nodes))
+			}
+			test(t, target)
+		})
+	}
+}
+
+// uniqueName returns a dataset name no other test uses.
+func uniqueName(t *testing.T) string {
+	return fmt.Sprintf("conformance-%s-%d", strings.Replace(t.Name(), "/", "-", -1), time.Now().UnixNano())
+}
+
+// extended returns the client of target as a flocker.ExtendedClientable, the
+// test is skipped if it is not one.
+func extended(t *testing.T, target Target) flocker.ExtendedClientable {
+	t.Helper()
+
+	c, ok := target.Client.(flocker.ExtendedClientable)
+	if !ok {
+		t.Skipf("%T does not implement flocker.ExtendedClientable", target.Client)
+	}
+	return c
+}
+
+// create creates a dataset with the given name, it is deleted once the test
+// is done.
+func create(t *testing.T, target Target, name string) *flocker.DatasetState {
+	t.Helper()
+
+	s, err := target.Client.CreateDataset(&flocker.CreateDatasetOptions{
+		Metadata: map[string]string{"name": name},
+	})
+	if err != nil {
+		t.Fatalf("Creating dataset %s failed: %s", name, err)
+	}
+	t.Cleanup(func() {
+		if c, ok := target.Client.(flocker.ExtendedClientable); ok {
+			c.DeleteDatasetAndWait(s.DatasetID, &flocker.DeleteOptions{IgnoreNotFound: true})
+		} else {
+			target.Client.DeleteDataset(s.DatasetID)
+		}
+	})
+	return s
+}
+
+func testCreateDataset(t *testing.T, target Target) {
+	assert := assert.New(t)
+
+	primary, err := target.Client.GetPrimaryUUID()
+	assert.NoError(err)
+	assert.Equal(target.Nodes[0], primary)
+
+	s := create(t, target, uniqueName(t))
+	assert.NotEmpty(s.DatasetID)
+	assert.Equal(primary, s.Primary)
+
+	state, err := target.Client.GetDatasetState(s.DatasetID)
+	if assert.NoError(err) {
+		assert.Equal(s.DatasetID, state.DatasetID)
+		assert.Equal(primary, state.Primary)
+	}
+}
+
+func testCreateDatasetConflict(t *testing.T, target Target) {
+	assert := assert.New(t)
+
+	s := create(t, target, uniqueName(t))
+	_, err := target.Client.CreateDataset(&flocker.CreateDatasetOptions{DatasetID: s.DatasetID})
+	assert.True(errors.Is(err, flocker.ErrVolumeAlreadyExists), "%v", err)
+	assert.True(errors.Is(err, flocker.ErrConflict), "%v", err)
+}
+
+func testLookup(t *testing.T, target Target) {
+	assert := assert.New(t)
+
+	name := uniqueName(t)
+	s := create(t, target, name)
+
+	id, err := target.Client.GetDatasetID(name)
+	assert.NoError(err)
+	assert.Equal(s.DatasetID, id)
+
+	_, err = target.Client.GetDatasetID(uniqueName(t))
+	assert.True(errors.Is(err, flocker.ErrConfigurationNotFound), "%v", err)
+	assert.True(errors.Is(err, flocker.ErrNotFound), "%v", err)
+
+	_, err = target.Client.GetDatasetState("00000000-0000-0000-0000-000000000000")
+	assert.True(errors.Is(err, flocker.ErrStateNotFound), "%v", err)
+	assert.True(errors.Is(err, flocker.ErrNotFound), "%v", err)
+
+	c := extended(t, target)
+	d, err := c.GetDataset(s.DatasetID)
+	if assert.NoError(err) {
+		assert.Equal(name, d.Metadata["name"])
+		assert.False(d.Deleted)
+	}
+
+	_, err = c.GetDataset("00000000-0000-0000-0000-000000000000")
+	assert.True(errors.Is(err, flocker.ErrDatasetNotFound), "%v", err)
+	assert.True(errors.Is(err, flocker.ErrNotFound), "%v", err)
+}
+
+func testLookupAmbiguousName(t *testing.T, target Target) {
+	assert := assert.New(t)
+
+	name := uniqueName(t)
+	s1 := create(t, target, name)
+	s2 := create(t, target, name)
+
+	_, err := target.Client.GetDatasetID(name)
+	assert.True(errors.Is(err, flocker.ErrAmbiguousName), "%v", err)
+	var errAmbiguous *flocker.AmbiguousNameError
+	if assert.True(errors.As(err, &errAmbiguous), "%v", err) {
+		assert.ElementsMatch([]string{s1.DatasetID, s2.DatasetID}, errAmbiguous.DatasetIDs)
+	}
+
+	if c, ok := target.Client.(flocker.ExtendedClientable); ok {
+		duplicates, err := c.FindDuplicateNames()
+		assert.NoError(err)
+		assert.ElementsMatch([]string{s1.DatasetID, s2.DatasetID}, duplicates[name])
+	}
+
+	// Deleted datasets do not count, even before they are gone from the state
+	assert.NoError(target.Client.DeleteDataset(s1.DatasetID))
+	id, err := target.Client.GetDatasetID(name)
+	assert.NoError(err)
+	assert.Equal(s2.DatasetID, id)
+}
+
+func testList(t *testing.T, target Target) {
+	assert := assert.New(t)
+	c := extended(t, target)
+
+	name := uniqueName(t)
+	s := create(t, target, name)
+
+	nodes, err := target.Client.ListNodes()
+	assert.NoError(err)
+	var uuids []string
+	for _, n := range nodes {
+		uuids = append(uuids, n.UUID)
+	}
+	assert.Subset(uuids, target.Nodes)
+
+	configurations, err := c.ListDatasetConfigurations()
+	assert.NoError(err)
+	assert.True(hasConfiguration(configurations, s.DatasetID), "%s is not configured", s.DatasetID)
+
+	states, err := c.ListDatasetStates()
+	assert.NoError(err)
+	var found bool
+	for _, state := range states {
+		found = found || state.DatasetID == s.DatasetID
+	}
+	assert.True(found, "%s has no state", s.DatasetID)
+
+	datasets, err := c.ListDatasets()
+	assert.NoError(err)
+	found = false
+	for _, d := range datasets {
+		found = found || d.DatasetID == s.DatasetID
+	}
+	assert.True(found, "%s is not listed", s.DatasetID)
+
+	selector, err := flocker.ParseSelector("name=" + name)
+	assert.NoError(err)
+	datasets, err = c.FindDatasets(selector)
+	assert.NoError(err)
+	if assert.Len(datasets, 1) {
+		assert.Equal(s.DatasetID, datasets[0].DatasetID)
+	}
+}
+
+func hasConfiguration(configurations []flocker.DatasetConfiguration, datasetID string) bool {
+	for _, c := range configurations {
+		if c.DatasetID == datasetID && !c.Deleted {
+			return true
+		}
+	}
+	return false
+}
+
+func testMove(t *testing.T, target Target) {
+	assert := assert.New(t)
+	c := extended(t, target)
+
+	s := create(t, target, uniqueName(t))
+
+	moved, err := c.MoveDataset(s.DatasetID, target.Nodes[1], nil)
+	if assert.NoError(err) {
+		assert.Equal(target.Nodes[1], moved.Primary)
+		assert.NotEmpty(moved.Path)
+	}
+
+	d, err := c.GetDataset(s.DatasetID)
+	if assert.NoError(err) {
+		assert.Equal(target.Nodes[1], d.DesiredPrimary)
+		assert.Equal(target.Nodes[1], d.ActualPrimary)
+	}
+
+	_, err = c.MoveDataset("00000000-0000-0000-0000-000000000000", target.Nodes[1], nil)
+	assert.True(errors.Is(err, flocker.ErrNotFound), "%v", err)
+}
+
+func testDelete(t *testing.T, target Target) {
+	assert := assert.New(t)
+	c := extended(t, target)
+
+	name := uniqueName(t)
+	s := create(t, target, name)
+
+	assert.NoError(c.DeleteDatasetAndWait(s.DatasetID, nil))
+
+	configurations, err := c.ListDatasetConfigurations()
+	assert.NoError(err)
+	assert.False(hasConfiguration(configurations, s.DatasetID), "%s is still configured", s.DatasetID)
+
+	_, err = target.Client.GetDatasetState(s.DatasetID)
+	assert.True(errors.Is(err, flocker.ErrStateNotFound), "%v", err)
+
+	_, err = target.Client.GetDatasetID(name)
+	assert.True(errors.Is(err, flocker.ErrConfigurationNotFound), "%v", err)
+
+	assert.NoError(c.DeleteDatasetAndWait(s.DatasetID, &flocker.DeleteOptions{IgnoreNotFound: true}))
+	assert.True(errors.Is(target.Client.DeleteDataset("00000000-0000-0000-0000-000000000000"), flocker.ErrNotFound))
+}
+
+func testLeases(t *testing.T, target Target) {
+	assert := assert.New(t)
+
+	s := create(t, target, uniqueName(t))
+
+	lease, err := target.Client.AcquireLease(s.DatasetID, target.Nodes[0], time.Minute)
+	if assert.NoError(err) {
+		assert.Equal(s.DatasetID, lease.DatasetID)
+		assert.Equal(target.Nodes[0], lease.NodeUUID)
+		assert.True(lease.Expires > 0 && lease.Expires <= time.Minute, "%s", lease.Expires)
+	}
+
+	_, err = target.Client.AcquireLease(s.DatasetID, target.Nodes[1], 0)
+	assert.True(errors.Is(err, flocker.ErrConflict), "%v", err)
+
+	leases, err := target.Client.ListLeases()
+	assert.NoError(err)
+	var found bool
+	for _, l := range leases {
+		found = found || l.DatasetID == s.DatasetID
+	}
+	assert.True(found, "the lease on %s is not listed", s.DatasetID)
+
+	assert.NoError(target.Client.ReleaseLease(s.DatasetID))
+	assert.True(errors.Is(target.Client.ReleaseLease(s.DatasetID), flocker.ErrNotFound))
+}
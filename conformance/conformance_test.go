@@ -0,0 +1,42 @@
+package conformance
+
+import (
+	"testing"
+	"time"
+
+	flocker "github.com/ClusterHQ/flocker-go"
+	"github.com/ClusterHQ/flocker-go/flockertest"
+)
+
+// newFakeTarget returns a Client talking to a fake control service.
+func newFakeTarget(t *testing.T) Target {
+	fake := flockertest.NewServer(
+		flockertest.WithConvergenceDelay(20*time.Millisecond),
+		flockertest.WithNodes(
+			flockertest.Node{UUID: "n1", Host: flockertest.DefaultNodeHost},
+			flockertest.Node{UUID: "n2", Host: "10.0.0.2"},
+		),
+	)
+	t.Cleanup(fake.Close)
+
+	c, err := flocker.NewClient(fake.Host(), fake.Port(), flockertest.DefaultNodeHost, "", "", "",
+		flocker.WithHTTPClient(fake.Client()),
+		flocker.WithPollInterval(5*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Target{Client: c, Nodes: []string{"n1", "n2"}}
+}
+
+func TestClientConformance(t *testing.T) {
+	RunClientableConformance(t, newFakeTarget)
+}
+
+func TestRecordingClientConformance(t *testing.T) {
+	RunClientableConformance(t, func(t *testing.T) Target {
+		target := newFakeTarget(t)
+		target.Client = flocker.NewRecordingClient(target.Client)
+		return target
+	})
+}
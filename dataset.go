@@ -0,0 +1,153 @@
+package flocker
+
+import (
+	"context"
+)
+
+// Dataset joins the configuration of a dataset, what the control service
+// wants it to be, with its state, what the dataset agents report it is.
+type Dataset struct {
+	DatasetID string
+	Metadata  map[string]string
+
+	// Deleted is set once the dataset was deleted from the configuration.
+	Deleted bool
+	// HasState is set when the dataset agents report the dataset.
+	HasState bool
+
+	DesiredPrimary string
+	ActualPrimary  string
+	DesiredSize    Size
+	ActualSize     Size
+	Path           string
+
+	// Converged is set when the state matches the configuration: the dataset
+	// is mounted with the desired size on the desired primary or, if it was
+	// deleted, it is gone.
+	Converged bool
+	// PendingDeletion is set when the dataset was deleted from the
+	// configuration but it still exists.
+	PendingDeletion bool
+}
+
+// newDataset joins a configuration with its state, which is nil when the
+// dataset has none.
+func newDataset(configuration DatasetConfiguration, state *DatasetState) Dataset {
+	d := Dataset{
+		DatasetID:      configuration.DatasetID,
+		Metadata:       configuration.Metadata,
+		Deleted:        configuration.Deleted,
+		DesiredPrimary: configuration.Primary,
+		DesiredSize:    configuration.MaximumSize,
+	}
+
+	if state != nil {
+		d.HasState = true
+		d.ActualPrimary = state.Primary
+		d.ActualSize = state.MaximumSize
+		d.Path = state.Path
+	}
+
+	if d.Deleted {
+		d.PendingDeletion = d.HasState
+		d.Converged = !d.HasState
+	} else {
+		d.Converged = d.HasState &&
+			d.Path != "" &&
+			d.ActualPrimary == d.DesiredPrimary &&
+			(d.DesiredSize == 0 || d.ActualSize == d.DesiredSize)
+	}
+	return d
+}
+
+// ListDatasets returns every configured dataset, including the deleted ones,
+// joined with its state.
+func (c Client) ListDatasets() ([]Dataset, error) {
+	return c.ListDatasetsContext(context.Background())
+}
+
+// ListDatasetsContext is like ListDatasets but the requests are bound to ctx.
+func (c Client) ListDatasetsContext(ctx context.Context) ([]Dataset, error) {
+	datasets, _, err := c.listDatasets(ctx)
+	return datasets, err
+}
+
+// listDatasets returns every configured dataset joined with its state, along
+// with the tag of the configuration.
+func (c Client) listDatasets(ctx context.Context) ([]Dataset, string, error) {
+	configurations, tag, err := c.listConfigurations(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	states, err := c.ListDatasetStatesContext(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	statesByID := make(map[string]*DatasetState, len(states))
+	for i := range states {
+		statesByID[states[i].DatasetID] = &states[i]
+	}
+
+	datasets := make([]Dataset, 0, len(configurations))
+	for _, configuration := range configurations {
+		datasets = append(datasets, newDataset(configuration, statesByID[configuration.DatasetID]))
+	}
+	return datasets, tag, nil
+}
+
+// GetDataset returns the configuration of the given dataset joined with its
+// state, if it is not configured it fails with ErrDatasetNotFound.
+func (c Client) GetDataset(datasetID string) (*Dataset, error) {
+	return c.GetDatasetContext(context.Background(), datasetID)
+}
+
+// GetDatasetContext is like GetDataset but the requests are bound to ctx.
+func (c Client) GetDatasetContext(ctx context.Context, datasetID string) (*Dataset, error) {
+	d, _, err := c.GetDatasetWithTagContext(ctx, datasetID)
+	return d, err
+}
+
+// GetDatasetWithTag is like GetDataset but also returns the tag of the
+// configuration the dataset was read from, see IfConfigurationMatches.
+func (c Client) GetDatasetWithTag(datasetID string) (*Dataset, string, error) {
+	return c.GetDatasetWithTagContext(context.Background(), datasetID)
+}
+
+// GetDatasetWithTagContext is like GetDatasetWithTag but the requests are
+// bound to ctx.
+func (c Client) GetDatasetWithTagContext(ctx context.Context, datasetID string) (*Dataset, string, error) {
+	datasets, tag, err := c.listDatasets(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, d := range datasets {
+		if d.DatasetID == datasetID {
+			return &d, tag, nil
+		}
+	}
+	return nil, "", ErrDatasetNotFound
+}
+
+// FindDatasets returns the datasets, not deleted, whose metadata matches the
+// selector.
+func (c Client) FindDatasets(selector Selector) ([]Dataset, error) {
+	return c.FindDatasetsContext(context.Background(), selector)
+}
+
+// FindDatasetsContext is like FindDatasets but the requests are bound to ctx.
+func (c Client) FindDatasetsContext(ctx context.Context, selector Selector) ([]Dataset, error) {
+	datasets, err := c.ListDatasetsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Dataset
+	for _, d := range datasets {
+		if !d.Deleted && selector.Matches(d.Metadata) {
+			found = append(found, d)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,120 @@
+package flocker
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// datasetsHandler answers the listings of the dataset configurations and
+// states with the given JSON.
+func datasetsHandler(assert *assert.Assertions, configurations, states string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("GET", r.Method)
+		switch r.URL.Path {
+		case "/v1/configuration/datasets":
+			w.Write([]byte(configurations))
+		case "/v1/state/datasets":
+			w.Write([]byte(states))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func TestListDatasets(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, datasetsHandler(assert, `[
+		{"dataset_id": "converged", "primary": "p1", "maximum_size": 1024, "metadata": {"name": "a"}},
+		{"dataset_id": "moving", "primary": "p2", "maximum_size": 1024},
+		{"dataset_id": "creating", "primary": "p1"},
+		{"dataset_id": "deleting", "primary": "p1", "deleted": true},
+		{"dataset_id": "deleted", "primary": "p1", "deleted": true}
+	]`, `[
+		{"dataset_id": "converged", "primary": "p1", "maximum_size": 1024, "path": "/flocker/converged"},
+		{"dataset_id": "moving", "primary": "p1", "maximum_size": 1024, "path": "/flocker/moving"},
+		{"dataset_id": "deleting", "primary": "p1", "path": "/flocker/deleting"}
+	]`))
+	defer done()
+
+	datasets, err := c.ListDatasets()
+	assert.NoError(err)
+	assert.Equal(5, len(datasets))
+
+	assert.Equal(Dataset{
+		DatasetID:      "converged",
+		Metadata:       map[string]string{"name": "a"},
+		HasState:       true,
+		DesiredPrimary: "p1",
+		ActualPrimary:  "p1",
+		DesiredSize:    1024,
+		ActualSize:     1024,
+		Path:           "/flocker/converged",
+		Converged:      true,
+	}, datasets[0])
+
+	assert.False(datasets[1].Converged)
+	assert.Equal("p2", datasets[1].DesiredPrimary)
+	assert.Equal("p1", datasets[1].ActualPrimary)
+
+	assert.False(datasets[2].HasState)
+	assert.False(datasets[2].Converged)
+
+	assert.True(datasets[3].PendingDeletion)
+	assert.False(datasets[3].Converged)
+
+	assert.False(datasets[4].PendingDeletion)
+	assert.True(datasets[4].Converged)
+}
+
+func TestGetDataset(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, datasetsHandler(assert,
+		`[{"dataset_id": "d1", "primary": "p1"}]`,
+		`[{"dataset_id": "d1", "primary": "p1", "path": "/flocker/d1"}]`,
+	))
+	defer done()
+
+	d, err := c.GetDataset("d1")
+	assert.NoError(err)
+	assert.Equal("/flocker/d1", d.Path)
+	assert.True(d.Converged)
+
+	_, err = c.GetDataset("unknown")
+	assert.Equal(ErrDatasetNotFound, err)
+	assert.True(errors.Is(err, ErrNotFound))
+}
+
+func TestFindDatasets(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, datasetsHandler(assert, `[
+		{"dataset_id": "d1", "metadata": {"name": "a", "owner": "alice", "tenant": "blue"}},
+		{"dataset_id": "d2", "metadata": {"name": "b", "owner": "bob"}},
+		{"dataset_id": "d3", "deleted": true, "metadata": {"name": "c", "owner": "alice"}}
+	]`, `[]`))
+	defer done()
+
+	selector, err := ParseSelector("owner=alice")
+	assert.NoError(err)
+
+	datasets, err := c.FindDatasets(selector)
+	assert.NoError(err)
+	if assert.Equal(1, len(datasets)) {
+		assert.Equal("d1", datasets[0].DatasetID)
+		assert.Equal(map[string]string{"name": "a", "owner": "alice", "tenant": "blue"}, datasets[0].Metadata)
+	}
+
+	selector, err = ParseSelector("owner in (alice,bob),!tenant")
+	assert.NoError(err)
+
+	datasets, err = c.FindDatasets(selector)
+	assert.NoError(err)
+	if assert.Equal(1, len(datasets)) {
+		assert.Equal("d2", datasets[0].DatasetID)
+	}
+}
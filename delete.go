@@ -0,0 +1,76 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DeleteOptions tunes DeleteDatasetAndWait, the zero value is fine.
+type DeleteOptions struct {
+	// Timeout overrides the client's wait timeout when it is not zero.
+	Timeout time.Duration
+	// IgnoreNotFound treats a dataset the control service does not know as
+	// already deleted, so deleting twice is not an error.
+	IgnoreNotFound bool
+}
+
+/*
+DeleteDatasetAndWait deletes the given dataset and waits until it is really
+gone: marked as deleted in the configuration and no longer reported by the
+dataset agents.
+
+If it is not gone in time an error matching ErrTimeout is returned.
+*/
+func (c Client) DeleteDatasetAndWait(datasetID string, opts *DeleteOptions) error {
+	return c.DeleteDatasetAndWaitContext(context.Background(), datasetID, opts)
+}
+
+// DeleteDatasetAndWaitContext is like DeleteDatasetAndWait but every request,
+// as well as the wait for the dataset to be gone, is bound to ctx.
+func (c Client) DeleteDatasetAndWaitContext(ctx context.Context, datasetID string, opts *DeleteOptions) error {
+	if opts == nil {
+		opts = &DeleteOptions{}
+	}
+	if opts.Timeout > 0 {
+		c.waitTimeout = opts.Timeout
+	}
+
+	if err := c.DeleteDatasetContext(ctx, datasetID); err != nil {
+		if !opts.IgnoreNotFound || !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	err := c.waitFor(ctx, func() (bool, error) {
+		return c.isDatasetGone(ctx, datasetID)
+	})
+
+	switch {
+	case err == nil:
+		return nil
+	case err == errWaitTimeout:
+		return fmt.Errorf("%w during dataset deletion (datasetID %s): it still exists", ErrTimeout, datasetID)
+	default:
+		return fmt.Errorf("Flocker API error during dataset deletion (datasetID %s): %w", datasetID, err)
+	}
+}
+
+// isDatasetGone says whether the dataset is deleted, or unknown, in the
+// configuration and absent from the state.
+func (c Client) isDatasetGone(ctx context.Context, datasetID string) (bool, error) {
+	configurations, err := c.ListDatasetConfigurationsContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	if findConfiguration(configurations, datasetID) != nil {
+		return false, nil
+	}
+
+	_, err = c.GetDatasetStateContext(ctx, datasetID)
+	if err == ErrStateNotFound {
+		return true, nil
+	}
+	return false, err
+}
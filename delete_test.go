@@ -0,0 +1,86 @@
+package flocker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// deleteServer forgets the state of its only dataset goneAfter polls after
+// it was deleted, never if it is zero.
+type deleteServer struct {
+	known      bool
+	deleted    bool
+	goneAfter  int
+	statePolls int
+	deletes    int
+}
+
+func (s *deleteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "DELETE":
+		s.deletes++
+		if !s.known {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"description": "Dataset not found."}`))
+			return
+		}
+		s.deleted = true
+		w.Write([]byte(`{"dataset_id": "d1", "deleted": true}`))
+	case r.URL.Path == "/v1/configuration/datasets":
+		if !s.known {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(fmt.Sprintf(`[{"dataset_id": "d1", "primary": "p1", "deleted": %t}]`, s.deleted)))
+	case r.URL.Path == "/v1/state/datasets":
+		s.statePolls++
+		if !s.known || s.deleted && s.goneAfter > 0 && s.statePolls >= s.goneAfter {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`[{"dataset_id": "d1", "primary": "p1", "path": "/flocker/d1"}]`))
+	}
+}
+
+func TestDeleteDatasetAndWait(t *testing.T) {
+	assert := assert.New(t)
+	s := &deleteServer{known: true, goneAfter: 4}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	assert.NoError(c.DeleteDatasetAndWait("d1", nil))
+	assert.Equal(1, s.deletes)
+	assert.Equal(4, s.statePolls)
+}
+
+func TestDeleteDatasetAndWaitTimesOut(t *testing.T) {
+	assert := assert.New(t)
+	s := &deleteServer{known: true}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	err := c.DeleteDatasetAndWait("d1", &DeleteOptions{Timeout: 5 * time.Second})
+	assert.True(errors.Is(err, ErrTimeout))
+	assert.Equal(6, s.statePolls)
+}
+
+func TestDeleteDatasetAndWaitNotFound(t *testing.T) {
+	assert := assert.New(t)
+	s := &deleteServer{}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	err := c.DeleteDatasetAndWait("d1", nil)
+	assert.True(errors.Is(err, ErrNotFound))
+
+	assert.NoError(c.DeleteDatasetAndWait("d1", &DeleteOptions{IgnoreNotFound: true}))
+	assert.Equal(2, s.deletes)
+}
@@ -0,0 +1,177 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrIncompatibleDataset is returned by EnsureDataset when a dataset with the
+// given name exists but does not fit the requested options.
+var ErrIncompatibleDataset error = &kindError{"The existing dataset is not compatible", ErrConflict}
+
+/*
+EnsureDataset returns the converged state of the dataset with the given
+metadata name, creating it with options if there is none.
+
+An existing dataset is only returned if it is compatible with options: at
+least as large as options.MaximumSize and on options.Primary, when they are
+set. Otherwise an error matching ErrIncompatibleDataset is returned.
+
+If another client creates the dataset at the same time, the control service
+may end up with two datasets with the name, it only refuses duplicate IDs.
+They are settled while waiting for the dataset to be ready: every client
+returns the one with the lowest ID, and deletes the one it created if it is
+not that one. Datasets created by other clients are never deleted. With
+WithDeterministicIDs only one dataset is ever created.
+*/
+func (c *Client) EnsureDataset(name string, options *CreateDatasetOptions) (*DatasetState, error) {
+	return c.EnsureDatasetContext(context.Background(), name, options)
+}
+
+// EnsureDatasetContext is like EnsureDataset but every request, as well as
+// the wait for the dataset to be ready, is bound to ctx.
+func (c *Client) EnsureDatasetContext(ctx context.Context, name string, options *CreateDatasetOptions) (*DatasetState, error) {
+	if options == nil {
+		options = &CreateDatasetOptions{}
+	}
+
+	// With deterministic IDs creating can only lose the race once, the second
+	// lookup finds the winner's dataset
+	for attempt := 0; attempt < 2; attempt++ {
+		configurations, err := c.ListDatasetConfigurationsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		configuration, err := c.findConfigurationByName(configurations, name)
+		if err == nil {
+			return c.ensureExisting(ctx, configuration, options)
+		} else if err != ErrConfigurationNotFound {
+			return nil, err
+		}
+
+		create := *options
+		create.Metadata = map[string]string{}
+		for k, v := range options.Metadata {
+			create.Metadata[k] = v
+		}
+		create.Metadata["name"] = name
+
+		datasetID, err := c.postDataset(ctx, &create)
+		if err == ErrVolumeAlreadyExists {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return c.settleCreated(ctx, name, datasetID, options)
+	}
+	return nil, fmt.Errorf("Dataset %s conflicts with an existing dataset but none was found with that name: %w", name, ErrVolumeAlreadyExists)
+}
+
+/*
+settleCreated waits until the dataset with the given name is ready, datasetID
+being the one just created.
+
+Every time it checks, the dataset with the name and the lowest ID is adopted,
+so clients racing to create it all end up with the same one. If datasetID is
+not that one it is deleted, the other datasets with the name are left to
+whoever created them. If the adopted dataset was created by another client it
+must be compatible with options.
+*/
+func (c *Client) settleCreated(ctx context.Context, name, datasetID string, options *CreateDatasetOptions) (*DatasetState, error) {
+	var (
+		configuration *DatasetConfiguration
+		s             *DatasetState
+	)
+	err := c.waitFor(ctx, func() (bool, error) {
+		configurations, err := c.ListDatasetConfigurationsContext(ctx)
+		if err != nil {
+			return false, err
+		}
+		ids := datasetIDsByName(configurations)[name]
+		if len(ids) == 0 {
+			return false, fmt.Errorf("Dataset %s was deleted while being created: %w", name, ErrConfigurationNotFound)
+		}
+		for _, id := range ids[1:] {
+			if id != datasetID {
+				continue
+			}
+			if err := c.DeleteDatasetContext(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+				return false, err
+			}
+		}
+		configuration = findConfiguration(configurations, ids[0])
+
+		var errState error
+		s, errState = c.GetDatasetStateContext(ctx, configuration.DatasetID)
+		if errState == ErrStateNotFound {
+			return false, nil
+		} else if errState != nil {
+			return false, errState
+		}
+		return s.Primary == configuration.Primary && s.Path != "", nil
+	})
+
+	// Only the dataset we created and kept is ours to clean up
+	ours := configuration != nil && configuration.DatasetID == datasetID
+	switch {
+	case err == nil && ours:
+		return s, nil
+	case err == nil:
+		if err := c.checkCompatible(configuration, options); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("Flocker API wait cancelled during dataset creation (datasetID %s): %w", datasetID, ctx.Err())
+	case err == errWaitTimeout:
+		var strErrDel string
+		if ours {
+			strErrDel = c.deleteCreated(datasetID)
+		}
+		return nil, fmt.Errorf("%w during dataset creation (datasetID %s): %w%s", ErrTimeout, datasetID, ErrStateNotFound, strErrDel)
+	default:
+		return nil, &CreateDatasetError{DatasetID: datasetID, Err: err}
+	}
+}
+
+// checkCompatible returns an error matching ErrIncompatibleDataset when the
+// existing dataset does not fit options.
+func (c Client) checkCompatible(configuration *DatasetConfiguration, options *CreateDatasetOptions) error {
+	if options.Primary != "" && options.Primary != configuration.Primary {
+		return fmt.Errorf("%w: dataset %s is on %s, not %s", ErrIncompatibleDataset, configuration.DatasetID, configuration.Primary, options.Primary)
+	}
+	if size := c.roundSize(options.MaximumSize); configuration.MaximumSize != 0 && size > configuration.MaximumSize {
+		return fmt.Errorf("%w: dataset %s has %s, less than %s", ErrIncompatibleDataset, configuration.DatasetID, configuration.MaximumSize, size)
+	}
+	return nil
+}
+
+// ensureExisting checks that the existing dataset is compatible with options
+// and waits until it is ready.
+func (c Client) ensureExisting(ctx context.Context, configuration *DatasetConfiguration, options *CreateDatasetOptions) (*DatasetState, error) {
+	if err := c.checkCompatible(configuration, options); err != nil {
+		return nil, err
+	}
+
+	var s *DatasetState
+	err := c.waitFor(ctx, func() (bool, error) {
+		var errState error
+		s, errState = c.GetDatasetStateContext(ctx, configuration.DatasetID)
+		if errState == ErrStateNotFound {
+			return false, nil
+		} else if errState != nil {
+			return false, errState
+		}
+		return s.Primary == configuration.Primary && s.Path != "", nil
+	})
+
+	switch {
+	case err == nil:
+		return s, nil
+	case err == errWaitTimeout:
+		return nil, fmt.Errorf("%w waiting for existing dataset (datasetID %s) to be ready", ErrTimeout, configuration.DatasetID)
+	default:
+		return nil, fmt.Errorf("Flocker API error waiting for existing dataset (datasetID %s): %w", configuration.DatasetID, err)
+	}
+}
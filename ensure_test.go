@@ -0,0 +1,107 @@
+package flocker
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ensureServer has the configurations listed in order: after a POST the next
+// listing is used, so a creation racing with another client can be faked.
+type ensureServer struct {
+	configurations []string
+	listings       int
+	posts          int
+	conflict       bool
+}
+
+func (s *ensureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/v1/state/nodes":
+		w.Write([]byte(`[{"host": "127.0.0.1", "uuid": "p1"}]`))
+	case r.Method == "GET" && r.URL.Path == "/v1/configuration/datasets":
+		i := s.listings
+		if i >= len(s.configurations) {
+			i = len(s.configurations) - 1
+		}
+		s.listings++
+		w.Write([]byte(s.configurations[i]))
+	case r.Method == "GET" && r.URL.Path == "/v1/state/datasets":
+		w.Write([]byte(`[{"dataset_id": "d1", "primary": "p1", "maximum_size": 1073741824, "path": "/flocker/d1"}]`))
+	case r.Method == "POST":
+		s.posts++
+		if s.conflict {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Write([]byte(`{"dataset_id": "d1"}`))
+	}
+}
+
+const existingConfiguration = `[{"dataset_id": "d1", "primary": "p1", "maximum_size": 1073741824, "metadata": {"name": "db"}}]`
+
+func TestEnsureDatasetExisting(t *testing.T) {
+	assert := assert.New(t)
+	s := &ensureServer{configurations: []string{existingConfiguration}}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second))
+	defer done()
+
+	state, err := c.EnsureDataset("db", &CreateDatasetOptions{MaximumSize: GiB})
+	assert.NoError(err)
+	assert.Equal("d1", state.DatasetID)
+	assert.Equal(0, s.posts)
+}
+
+func TestEnsureDatasetIncompatible(t *testing.T) {
+	assert := assert.New(t)
+	s := &ensureServer{configurations: []string{existingConfiguration}}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second))
+	defer done()
+
+	_, err := c.EnsureDataset("db", &CreateDatasetOptions{Primary: "p2"})
+	assert.True(errors.Is(err, ErrIncompatibleDataset))
+
+	_, err = c.EnsureDataset("db", &CreateDatasetOptions{MaximumSize: 2 * GiB})
+	assert.True(errors.Is(err, ErrIncompatibleDataset))
+	assert.True(errors.Is(err, ErrConflict))
+	assert.Equal(0, s.posts)
+}
+
+func TestEnsureDatasetCreates(t *testing.T) {
+	assert := assert.New(t)
+	s := &ensureServer{configurations: []string{
+		`[{"dataset_id": "old", "deleted": true, "metadata": {"name": "db"}}]`,
+		`[{"dataset_id": "old", "deleted": true, "metadata": {"name": "db"}}, {"dataset_id": "d1", "primary": "p1", "metadata": {"name": "db", "owner": "alice"}}]`,
+	}}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second))
+	defer done()
+
+	metadata := map[string]string{"owner": "alice"}
+	state, err := c.EnsureDataset("db", &CreateDatasetOptions{Metadata: metadata})
+	assert.NoError(err)
+	assert.Equal("d1", state.DatasetID)
+	assert.Equal(1, s.posts)
+	assert.Equal(map[string]string{"owner": "alice"}, metadata, "the caller's metadata is untouched")
+}
+
+func TestEnsureDatasetLosesCreationRace(t *testing.T) {
+	assert := assert.New(t)
+	s := &ensureServer{
+		configurations: []string{`[]`, existingConfiguration},
+		conflict:       true,
+	}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second))
+	defer done()
+
+	state, err := c.EnsureDataset("db", nil)
+	assert.NoError(err)
+	assert.Equal("d1", state.DatasetID)
+	assert.Equal(1, s.posts)
+}
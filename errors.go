@@ -0,0 +1,122 @@
+package flocker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodySize limits how much of an error response body is read.
+const maxErrorBodySize = 64 * 1024
+
+var (
+	// ErrNotFound is matched by errors.Is for every error caused by a missing
+	// dataset, configuration or API resource.
+	ErrNotFound = errors.New("Not found")
+	// ErrConflict is matched by errors.Is when the control service refused a
+	// change because it conflicts with the existing configuration.
+	ErrConflict = errors.New("Conflict")
+	// ErrTimeout is matched by errors.Is when the control service did not
+	// converge, or answer, in time.
+	ErrTimeout = errors.New("Flocker API timeout")
+
+	// ErrStateNotFound is returned when a dataset has no state yet.
+	ErrStateNotFound error = &kindError{"State not found by Dataset ID", ErrNotFound}
+	// ErrConfigurationNotFound is returned when no dataset configuration has
+	// the searched name.
+	ErrConfigurationNotFound error = &kindError{"Configuration not found by Name", ErrNotFound}
+	// ErrDatasetNotFound is returned when no dataset has the searched ID.
+	ErrDatasetNotFound error = &kindError{"Dataset not found by Dataset ID", ErrNotFound}
+	// ErrVolumeAlreadyExists is returned when creating a dataset that already
+	// exists.
+	ErrVolumeAlreadyExists error = &kindError{"The volume already exists", ErrConflict}
+)
+
+// kindError is an error with its own message that also matches a more
+// generic sentinel such as ErrNotFound.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string { return e.msg }
+func (e *kindError) Unwrap() error { return e.kind }
+
+// APIError is returned when the control service answers with a non 2xx
+// status code.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	// Description is the description sent by the control service, or the
+	// raw response body when it is not the usual JSON error.
+	Description string
+	// Attempts is the number of times the request was sent, it is only set
+	// when the request was retried.
+	Attempts int
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("Flocker API error: %s %s returned %d", e.Method, e.Path, e.StatusCode)
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	if e.Attempts > 1 {
+		msg += fmt.Sprintf(" (after %d attempts)", e.Attempts)
+	}
+	return msg
+}
+
+// Is lets errors.Is match an APIError against ErrNotFound, ErrConflict,
+// ErrTimeout and ErrPreconditionFailed depending on its status code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrTimeout:
+		return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusGatewayTimeout
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	}
+	return false
+}
+
+// CreateDatasetError is returned by CreateDataset when the dataset was created
+// but waiting for it to be ready failed for another reason than a timeout. The
+// dataset is left in place, it is up to the caller to keep or delete it.
+type CreateDatasetError struct {
+	DatasetID string
+	Err       error
+}
+
+func (e *CreateDatasetError) Error() string {
+	return fmt.Sprintf("Flocker API error during dataset creation (datasetID %s): %s", e.DatasetID, e.Err)
+}
+
+func (e *CreateDatasetError) Unwrap() error { return e.Err }
+
+// newAPIError builds an APIError from the response, consuming its body.
+func newAPIError(resp *http.Response) *APIError {
+	e := &APIError{StatusCode: resp.StatusCode}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.Path = resp.Request.URL.Path
+	}
+
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	var payload struct {
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(b, &payload); err == nil && payload.Description != "" {
+		e.Description = payload.Description
+	} else {
+		e.Description = strings.TrimSpace(string(b))
+	}
+	return e
+}
@@ -0,0 +1,51 @@
+package flocker
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestResponse(method, path string, code int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		Request:    &http.Request{Method: method, URL: &url.URL{Path: path}},
+	}
+}
+
+func TestNewAPIErrorDecodesDescription(t *testing.T) {
+	assert := assert.New(t)
+
+	err := newAPIError(newTestResponse("POST", "/v1/configuration/datasets", 409, `{"description": "The provided dataset_id is already in use."}`))
+	assert.Equal("The provided dataset_id is already in use.", err.Description)
+	assert.Equal("Flocker API error: POST /v1/configuration/datasets returned 409: The provided dataset_id is already in use.", err.Error())
+	assert.True(errors.Is(err, ErrConflict))
+	assert.False(errors.Is(err, ErrNotFound))
+}
+
+func TestNewAPIErrorKeepsRawBody(t *testing.T) {
+	assert := assert.New(t)
+
+	err := newAPIError(newTestResponse("GET", "/v1/state/nodes", 504, "upstream timed out\n"))
+	assert.Equal("upstream timed out", err.Description)
+	assert.True(errors.Is(err, ErrTimeout))
+
+	err = newAPIError(newTestResponse("GET", "/v1/state/nodes", 500, ""))
+	assert.Equal("Flocker API error: GET /v1/state/nodes returned 500", err.Error())
+}
+
+func TestSentinelErrorsMatchTheirKind(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(errors.Is(ErrStateNotFound, ErrNotFound))
+	assert.True(errors.Is(ErrConfigurationNotFound, ErrNotFound))
+	assert.True(errors.Is(ErrVolumeAlreadyExists, ErrConflict))
+	assert.True(errors.Is(errWaitTimeout, ErrTimeout))
+	assert.False(errors.Is(ErrStateNotFound, ErrConflict))
+}
@@ -0,0 +1,216 @@
+package flockertest
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"time"
+)
+
+// AnyDataset makes NeverConverge and StallMoves apply to every dataset,
+// including the ones not created yet.
+const AnyDataset = "*"
+
+/*
+Fault is what goes wrong with a request to the fake, see InjectFaults. The
+zero Fault lets the request through untouched.
+
+Latency is added before anything else. A Status or a dropped connection
+replace the answer and the request is not handled, while a truncated or
+malformed answer is sent once the request has been handled, as when the
+answer is lost after the change was made.
+*/
+type Fault struct {
+	// Latency delays the answer.
+	Latency time.Duration
+	// Status, when not zero, is answered with a JSON error instead.
+	Status int
+	// Drop closes the connection without answering.
+	Drop bool
+	// Truncate cuts the body of the answer in half.
+	Truncate bool
+	// Malformed replaces the body of the answer with invalid JSON.
+	Malformed bool
+}
+
+// faultRule gives faults to the requests matching method and pattern.
+type faultRule struct {
+	method  string
+	pattern string
+	// script is the faults of the next matching requests, in order.
+	script []Fault
+	// fault is given with probability to every matching request once the
+	// script is over.
+	fault       Fault
+	probability float64
+}
+
+func (r *faultRule) matches(req *http.Request) bool {
+	if r.method != "" && r.method != req.Method {
+		return false
+	}
+	if r.pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(r.pattern, req.URL.Path)
+	return ok
+}
+
+// WithSeed seeds the choice of the probabilistic faults, see
+// InjectRandomFault. The seed is 1 by default so runs are repeatable.
+func WithSeed(seed int64) Option {
+	return func(s *Server) {
+		s.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+/*
+InjectFaults gives the faults, in order, to the next requests with the given
+method and a path matching pattern, see path.Match. An empty method or
+pattern matches everything, so "/v1/configuration/datasets/*" matches the
+changes of every dataset.
+
+Rules are tried in the order they were injected, the first one with a fault
+to give wins.
+*/
+func (s *Server) InjectFaults(method, pattern string, faults ...Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &faultRule{
+		method:  method,
+		pattern: pattern,
+		script:  append([]Fault{}, faults...),
+	})
+}
+
+// InjectRandomFault gives fault to every request matching method and
+// pattern, like in InjectFaults, with the given probability. A probability
+// of 1 makes it permanent, e.g. to add latency to an endpoint.
+func (s *Server) InjectRandomFault(method, pattern string, probability float64, fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &faultRule{
+		method:      method,
+		pattern:     pattern,
+		fault:       fault,
+		probability: probability,
+	})
+}
+
+// ClearFaults removes every injected fault and lets every dataset converge
+// again.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+	s.neverConverge = map[string]bool{}
+	s.stalledMoves = map[string]bool{}
+}
+
+// NeverConverge stops the state of the given dataset, or of every dataset
+// with AnyDataset, from catching up with its configuration.
+func (s *Server) NeverConverge(datasetID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.neverConverge[datasetID] = true
+}
+
+// StallMoves keeps the given dataset, or every dataset with AnyDataset, on
+// its current node: its other changes converge but it never arrives to a
+// new primary.
+func (s *Server) StallMoves(datasetID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stalledMoves[datasetID] = true
+}
+
+// ResumeConvergence undoes NeverConverge and StallMoves for the given
+// dataset, or for every dataset with AnyDataset.
+func (s *Server) ResumeConvergence(datasetID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if datasetID == AnyDataset {
+		s.neverConverge = map[string]bool{}
+		s.stalledMoves = map[string]bool{}
+		return
+	}
+	delete(s.neverConverge, datasetID)
+	delete(s.stalledMoves, datasetID)
+}
+
+// nextFault returns the fault of the request, if any. It must be called with
+// mu held.
+func (s *Server) nextFault(r *http.Request) (Fault, bool) {
+	for i, rule := range s.faults {
+		if !rule.matches(r) {
+			continue
+		}
+		if len(rule.script) > 0 {
+			f := rule.script[0]
+			rule.script = rule.script[1:]
+			if len(rule.script) == 0 && rule.probability == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+			return f, true
+		}
+		if rule.probability > 0 && s.rand.Float64() < rule.probability {
+			return rule.fault, true
+		}
+	}
+	return Fault{}, false
+}
+
+// withFaults serves the requests with next, giving them their faults.
+func (s *Server) withFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		f, ok := s.nextFault(r)
+		s.mu.Unlock()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		switch {
+		case f.Drop:
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			panic(http.ErrAbortHandler)
+		case f.Status != 0:
+			writeError(w, f.Status, "Injected fault")
+		case f.Truncate || f.Malformed:
+			rec := httptest.NewRecorder()
+			next.ServeHTTP(rec, r)
+
+			body := rec.Body.Bytes()
+			if f.Truncate {
+				body = body[:len(body)/2]
+			}
+			if f.Malformed {
+				body = []byte(`{"dataset_id": `)
+			}
+			for k, v := range rec.Header() {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(rec.Code)
+			w.Write(body)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
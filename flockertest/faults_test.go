@@ -0,0 +1,164 @@
+package flockertest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectFaultsScript(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	s.InjectFaults("GET", "/v1/state/*", Fault{Status: http.StatusServiceUnavailable}, Fault{}, Fault{Status: http.StatusBadGateway})
+
+	// Other methods and paths are not affected
+	resp := do(assert, s, "GET", "/v1/version", nil, nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+
+	resp = do(assert, s, "GET", "/v1/state/nodes", nil, nil, nil)
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	resp = do(assert, s, "GET", "/v1/state/datasets", nil, nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	resp = do(assert, s, "GET", "/v1/state/nodes", nil, nil, nil)
+	assert.Equal(http.StatusBadGateway, resp.StatusCode)
+
+	// The script is over
+	resp = do(assert, s, "GET", "/v1/state/nodes", nil, nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func TestInjectFaultsStatusSkipsRequest(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	s.InjectFaults("POST", datasetsPath, Fault{Status: http.StatusInternalServerError})
+	do(assert, s, "POST", datasetsPath, map[string]interface{}{"primary": "n1"}, nil, nil)
+
+	var configurations []configuration
+	do(assert, s, "GET", datasetsPath, nil, nil, &configurations)
+	assert.Empty(configurations)
+}
+
+func TestInjectFaultsBody(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	s.InjectFaults("POST", datasetsPath, Fault{Truncate: true}, Fault{Malformed: true})
+	body := map[string]interface{}{"primary": "n1"}
+
+	for i := 0; i < 2; i++ {
+		resp := do(assert, s, "POST", datasetsPath, body, nil, nil)
+		assert.Equal(http.StatusCreated, resp.StatusCode)
+	}
+
+	// The datasets were created even though the answers were broken
+	var configurations []configuration
+	do(assert, s, "GET", datasetsPath, nil, nil, &configurations)
+	assert.Len(configurations, 2)
+}
+
+func TestInjectFaultsBrokenJSON(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	s.InjectFaults("", "/v1/version", Fault{Truncate: true}, Fault{Malformed: true})
+	for i := 0; i < 2; i++ {
+		resp, err := s.Client().Get(s.URL + "/v1/version")
+		assert.NoError(err)
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(err)
+		assert.Error(json.Unmarshal(b, &map[string]string{}), string(b))
+	}
+}
+
+func TestInjectFaultsDrop(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	s.InjectFaults("", "", Fault{Drop: true})
+	_, err := s.Client().Get(s.URL + "/v1/version")
+	assert.Error(err)
+
+	_, err = s.Client().Get(s.URL + "/v1/version")
+	assert.NoError(err)
+}
+
+func TestInjectFaultsLatency(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	s.InjectRandomFault("GET", "/v1/version", 1, Fault{Latency: 50 * time.Millisecond})
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		resp := do(assert, s, "GET", "/v1/version", nil, nil, nil)
+		assert.Equal(http.StatusOK, resp.StatusCode)
+		assert.True(time.Since(start) >= 50*time.Millisecond)
+	}
+
+	s.ClearFaults()
+	start := time.Now()
+	do(assert, s, "GET", "/v1/version", nil, nil, nil)
+	assert.True(time.Since(start) < 50*time.Millisecond)
+}
+
+func TestInjectRandomFaultSeed(t *testing.T) {
+	assert := assert.New(t)
+
+	statuses := func(seed int64) []int {
+		s := NewServer(WithSeed(seed))
+		defer s.Close()
+		s.InjectRandomFault("GET", "", 0.5, Fault{Status: http.StatusServiceUnavailable})
+
+		var codes []int
+		for i := 0; i < 20; i++ {
+			codes = append(codes, do(assert, s, "GET", "/v1/version", nil, nil, nil).StatusCode)
+		}
+		return codes
+	}
+
+	codes := statuses(42)
+	assert.Equal(codes, statuses(42))
+	assert.Contains(codes, http.StatusOK)
+	assert.Contains(codes, http.StatusServiceUnavailable)
+}
+
+func TestNeverConvergeAndStallMoves(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	var c configuration
+	do(assert, s, "POST", datasetsPath, map[string]interface{}{"primary": "n1"}, nil, &c)
+	s.Converge()
+
+	s.StallMoves(c.DatasetID)
+	do(assert, s, "POST", datasetsPath+"/"+c.DatasetID, map[string]interface{}{"primary": "n2", "maximum_size": 2048}, nil, nil)
+
+	var states []state
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Equal("n1", states[0].Primary)
+	assert.Equal(int64(2048), states[0].MaximumSize)
+
+	s.ResumeConvergence(c.DatasetID)
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Equal("n2", states[0].Primary)
+
+	s.NeverConverge(AnyDataset)
+	do(assert, s, "POST", datasetsPath, map[string]interface{}{"primary": "n1"}, nil, nil)
+	do(assert, s, "DELETE", datasetsPath+"/"+c.DatasetID, nil, nil, nil)
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Len(states, 1)
+	assert.Equal(c.DatasetID, states[0].DatasetID)
+}
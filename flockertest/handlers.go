@@ -0,0 +1,288 @@
+package flockertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	configurationTagHeader       = "X-Configuration-Tag"
+	ifConfigurationMatchesHeader = "X-If-Configuration-Matches"
+
+	datasetsPath = "/v1/configuration/datasets"
+	leasesPath   = "/v1/configuration/leases"
+)
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/version", s.getOnly(s.handleVersion))
+	mux.HandleFunc("/v1/state/nodes", s.getOnly(s.handleNodes))
+	mux.HandleFunc("/v1/state/datasets", s.getOnly(s.handleStates))
+	mux.HandleFunc(datasetsPath, s.handleDatasets)
+	mux.HandleFunc(datasetsPath+"/", s.handleDataset)
+	mux.HandleFunc(leasesPath, s.handleLeases)
+	mux.HandleFunc(leasesPath+"/", s.handleLease)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "Not found: %s", r.URL.Path)
+	})
+	return mux
+}
+
+// getOnly refuses every method but GET.
+func (s *Server) getOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writeError(w, http.StatusMethodNotAllowed, "Method %s not allowed on %s", r.Method, r.URL.Path)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with the JSON error body of the control service.
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]string{"description": fmt.Sprintf(format, args...)})
+}
+
+// preconditionFailed answers 412 and returns true if the request is only
+// valid for another configuration than the current one. It must be called
+// with mu held.
+func (s *Server) preconditionFailed(w http.ResponseWriter, r *http.Request) bool {
+	tag := r.Header.Get(ifConfigurationMatchesHeader)
+	if tag == "" || tag == s.tag() {
+		return false
+	}
+	writeError(w, http.StatusPreconditionFailed, "Configuration tag is %s, not %s", s.tag(), tag)
+	return true
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"flocker": s.version})
+}
+
+func (s *Server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.nodes)
+}
+
+func (s *Server) handleStates(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.converge(time.Now())
+	states := []state{}
+	for _, d := range s.datasets {
+		if d.state != nil {
+			states = append(states, *d.state)
+		}
+	}
+	writeJSON(w, http.StatusOK, states)
+}
+
+func (s *Server) handleDatasets(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "GET":
+		configurations := []configuration{}
+		for _, d := range s.datasets {
+			configurations = append(configurations, d.config)
+		}
+		w.Header().Set(configurationTagHeader, s.tag())
+		writeJSON(w, http.StatusOK, configurations)
+	case "POST":
+		s.createDataset(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method %s not allowed on %s", r.Method, r.URL.Path)
+	}
+}
+
+// createDataset handles POST /v1/configuration/datasets. It must be called
+// with mu held.
+func (s *Server) createDataset(w http.ResponseWriter, r *http.Request) {
+	var c configuration
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON: %s", err)
+		return
+	}
+	if c.Primary == "" {
+		writeError(w, http.StatusBadRequest, "The primary is required")
+		return
+	}
+	if c.MaximumSize < 0 || c.MaximumSize%1024 != 0 {
+		writeError(w, http.StatusBadRequest, "The maximum size must be a positive multiple of 1024")
+		return
+	}
+	if c.DatasetID == "" {
+		c.DatasetID = newDatasetID()
+	}
+	if c.Metadata == nil {
+		c.Metadata = map[string]string{}
+	}
+	c.Deleted = false
+
+	if s.preconditionFailed(w, r) {
+		return
+	}
+	// Deleted datasets keep their ID
+	if s.find(c.DatasetID) != nil {
+		writeError(w, http.StatusConflict, "The provided dataset_id is already in use.")
+		return
+	}
+
+	d := &dataset{config: c}
+	s.datasets = append(s.datasets, d)
+	s.changed(d)
+	writeJSON(w, http.StatusCreated, d.config)
+}
+
+// handleDataset handles the changes of /v1/configuration/datasets/{id}.
+func (s *Server) handleDataset(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	datasetID := strings.TrimPrefix(r.URL.Path, datasetsPath+"/")
+	if r.Method != "POST" && r.Method != "DELETE" {
+		writeError(w, http.StatusMethodNotAllowed, "Method %s not allowed on %s", r.Method, r.URL.Path)
+		return
+	}
+
+	d := s.find(datasetID)
+	if d == nil || d.config.Deleted {
+		writeError(w, http.StatusNotFound, "Dataset not found.")
+		return
+	}
+
+	var update struct {
+		Primary     *string            `json:"primary"`
+		MaximumSize *int64             `json:"maximum_size"`
+		Metadata    *map[string]string `json:"metadata"`
+	}
+	if r.Method == "POST" {
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %s", err)
+			return
+		}
+		if update.MaximumSize != nil && (*update.MaximumSize <= 0 || *update.MaximumSize%1024 != 0) {
+			writeError(w, http.StatusBadRequest, "The maximum size must be a positive multiple of 1024")
+			return
+		}
+	}
+
+	if s.preconditionFailed(w, r) {
+		return
+	}
+
+	if r.Method == "DELETE" {
+		d.config.Deleted = true
+	}
+	if update.Primary != nil {
+		d.config.Primary = *update.Primary
+	}
+	if update.MaximumSize != nil {
+		d.config.MaximumSize = *update.MaximumSize
+	}
+	if update.Metadata != nil {
+		d.config.Metadata = *update.Metadata
+		if d.config.Metadata == nil {
+			d.config.Metadata = map[string]string{}
+		}
+	}
+	s.changed(d)
+	writeJSON(w, http.StatusOK, d.config)
+}
+
+type leasePayload struct {
+	DatasetID string   `json:"dataset_id"`
+	NodeUUID  string   `json:"node_uuid"`
+	Expires   *float64 `json:"expires"`
+}
+
+func (l *lease) payload(now time.Time) leasePayload {
+	p := leasePayload{DatasetID: l.datasetID, NodeUUID: l.nodeUUID}
+	if !l.expires.IsZero() {
+		seconds := l.expires.Sub(now).Seconds()
+		p.Expires = &seconds
+	}
+	return p
+}
+
+func (s *Server) handleLeases(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.expireLeases(now)
+
+	switch r.Method {
+	case "GET":
+		leases := []leasePayload{}
+		for _, l := range s.sortedLeases() {
+			leases = append(leases, l.payload(now))
+		}
+		writeJSON(w, http.StatusOK, leases)
+	case "POST":
+		var p leasePayload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON: %s", err)
+			return
+		}
+		if p.DatasetID == "" || p.NodeUUID == "" {
+			writeError(w, http.StatusBadRequest, "The dataset_id and node_uuid are required")
+			return
+		}
+
+		status := http.StatusCreated
+		if l, ok := s.leases[p.DatasetID]; ok {
+			if l.nodeUUID != p.NodeUUID {
+				writeError(w, http.StatusConflict, "The lease on %s is held by %s", l.datasetID, l.nodeUUID)
+				return
+			}
+			// Acquiring again renews the lease
+			status = http.StatusOK
+		}
+
+		l := &lease{datasetID: p.DatasetID, nodeUUID: p.NodeUUID}
+		if p.Expires != nil {
+			l.expires = now.Add(time.Duration(*p.Expires * float64(time.Second)))
+		}
+		s.leases[l.datasetID] = l
+		writeJSON(w, status, l.payload(now))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method %s not allowed on %s", r.Method, r.URL.Path)
+	}
+}
+
+// handleLease handles the release of /v1/configuration/leases/{id}.
+func (s *Server) handleLease(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method != "DELETE" {
+		writeError(w, http.StatusMethodNotAllowed, "Method %s not allowed on %s", r.Method, r.URL.Path)
+		return
+	}
+
+	now := time.Now()
+	s.expireLeases(now)
+
+	datasetID := strings.TrimPrefix(r.URL.Path, leasesPath+"/")
+	l, ok := s.leases[datasetID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No lease on %s", datasetID)
+		return
+	}
+	delete(s.leases, datasetID)
+	writeJSON(w, http.StatusOK, l.payload(now))
+}
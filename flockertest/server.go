@@ -0,0 +1,287 @@
+/*
+Package flockertest provides a fake Flocker Control Service to test code using
+the flocker package without a Flocker cluster.
+
+The fake keeps its configuration in memory and brings the state of its
+datasets in line with it after a configurable convergence delay, like the
+dataset agents of a real cluster would:
+
+	fake := flockertest.NewServer(flockertest.WithConvergenceDelay(time.Second))
+	defer fake.Close()
+
+	c, err := flocker.NewClient(fake.Host(), fake.Port(), "127.0.0.1", "", "", "",
+		flocker.WithHTTPClient(fake.Client()))
+*/
+package flockertest
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultNodeUUID is the UUID of the node the fake starts with.
+	DefaultNodeUUID = "00000000-0000-0000-0000-000000000001"
+	// DefaultNodeHost is the host of the node the fake starts with, the
+	// address a client running the tests usually has.
+	DefaultNodeHost = "127.0.0.1"
+	// DefaultVersion is the Flocker version the fake reports by default.
+	DefaultVersion = "1.15.0"
+)
+
+// Node is a dataset agent node of the fake.
+type Node struct {
+	UUID string `json:"uuid"`
+	Host string `json:"host"`
+}
+
+// Option configures a Server, see NewServer.
+type Option func(*Server)
+
+// WithConvergenceDelay makes the state of a dataset catch up with a change
+// of its configuration only after d. It is zero by default: the state is
+// updated at once.
+func WithConvergenceDelay(d time.Duration) Option {
+	return func(s *Server) {
+		s.convergenceDelay = d
+	}
+}
+
+// WithNodes replaces the nodes of the fake, a single node with
+// DefaultNodeUUID and DefaultNodeHost by default.
+func WithNodes(nodes ...Node) Option {
+	return func(s *Server) {
+		s.nodes = append([]Node{}, nodes...)
+	}
+}
+
+// WithVersion sets the Flocker version the fake reports, DefaultVersion by
+// default.
+func WithVersion(version string) Option {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+/*
+Server is a fake Flocker Control Service listening on a local TLS port. It
+implements the parts of the REST API the flocker package uses:
+
+	GET    /v1/version
+	GET    /v1/state/nodes
+	GET    /v1/state/datasets
+	GET    /v1/configuration/datasets
+	POST   /v1/configuration/datasets
+	POST   /v1/configuration/datasets/{dataset_id}
+	DELETE /v1/configuration/datasets/{dataset_id}
+	GET    /v1/configuration/leases
+	POST   /v1/configuration/leases
+	DELETE /v1/configuration/leases/{dataset_id}
+
+Changes honour the X-If-Configuration-Matches header and every configuration
+listing carries an X-Configuration-Tag header. Like in a real cluster, a
+dataset with a lease is not moved or deleted from the node holding it until
+the lease is released or expires.
+
+Faults can be injected to exercise the error paths of the clients, see
+InjectFaults, NeverConverge and StallMoves.
+
+A Server is safe for concurrent use.
+*/
+type Server struct {
+	*httptest.Server
+
+	convergenceDelay time.Duration
+	version          string
+
+	mu         sync.Mutex
+	nodes      []Node
+	datasets   []*dataset
+	leases     map[string]*lease
+	generation int
+
+	faults        []*faultRule
+	rand          *rand.Rand
+	neverConverge map[string]bool
+	stalledMoves  map[string]bool
+}
+
+// dataset is the configuration of a dataset along with its state, which
+// catches up with the configuration convergenceDelay after changedAt.
+type dataset struct {
+	config    configuration
+	state     *state
+	changedAt time.Time
+}
+
+type configuration struct {
+	DatasetID   string            `json:"dataset_id"`
+	Primary     string            `json:"primary"`
+	MaximumSize int64             `json:"maximum_size,omitempty"`
+	Metadata    map[string]string `json:"metadata"`
+	Deleted     bool              `json:"deleted"`
+}
+
+type state struct {
+	DatasetID   string `json:"dataset_id"`
+	Primary     string `json:"primary"`
+	MaximumSize int64  `json:"maximum_size,omitempty"`
+	Path        string `json:"path"`
+}
+
+type lease struct {
+	datasetID string
+	nodeUUID  string
+	// expires is zero for a lease which never expires.
+	expires time.Time
+}
+
+// NewServer starts a fake control service, it must be closed once done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		version: DefaultVersion,
+		nodes:   []Node{{UUID: DefaultNodeUUID, Host: DefaultNodeHost}},
+		leases:  map[string]*lease{},
+
+		rand:          rand.New(rand.NewSource(1)),
+		neverConverge: map[string]bool{},
+		stalledMoves:  map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewUnstartedServer(s.withFaults(s.handler()))
+	// Dropped connections are expected, not worth logging
+	s.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	s.StartTLS()
+	return s
+}
+
+// Host returns the host the fake listens on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port the fake listens on.
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// AddNode adds a dataset agent node to the fake.
+func (s *Server) AddNode(node Node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes = append(s.nodes, node)
+}
+
+// Converge brings the state of every dataset in line with its configuration
+// at once, as if the convergence delay was over.
+func (s *Server) Converge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.converge(time.Time{})
+}
+
+// converge updates the state of the datasets whose change is older than the
+// convergence delay, every dataset when now is zero. It must be called with
+// mu held.
+func (s *Server) converge(now time.Time) {
+	s.expireLeases(time.Now())
+	for _, d := range s.datasets {
+		id := d.config.DatasetID
+		if !now.IsZero() && now.Before(d.changedAt.Add(s.convergenceDelay)) {
+			continue
+		}
+		if s.neverConverge[id] || s.neverConverge[AnyDataset] {
+			continue
+		}
+		if l, ok := s.leases[d.config.DatasetID]; ok && d.state != nil {
+			if d.config.Deleted || d.config.Primary != l.nodeUUID {
+				// The lease holder keeps the dataset
+				continue
+			}
+		}
+		if d.config.Deleted {
+			d.state = nil
+			continue
+		}
+		primary := d.config.Primary
+		if d.state != nil && (s.stalledMoves[id] || s.stalledMoves[AnyDataset]) {
+			primary = d.state.Primary
+		}
+		d.state = &state{
+			DatasetID:   id,
+			Primary:     primary,
+			MaximumSize: d.config.MaximumSize,
+			Path:        "/flocker/" + id,
+		}
+	}
+}
+
+// expireLeases drops the leases past their expiry. It must be called with
+// mu held.
+func (s *Server) expireLeases(now time.Time) {
+	for id, l := range s.leases {
+		if !l.expires.IsZero() && !now.Before(l.expires) {
+			delete(s.leases, id)
+		}
+	}
+}
+
+// find returns the dataset with the given ID, deleted or not, or nil. It
+// must be called with mu held.
+func (s *Server) find(datasetID string) *dataset {
+	for _, d := range s.datasets {
+		if d.config.DatasetID == datasetID {
+			return d
+		}
+	}
+	return nil
+}
+
+// tag returns the tag of the current configuration. It must be called with
+// mu held.
+func (s *Server) tag() string {
+	return strconv.Itoa(s.generation)
+}
+
+// changed records a change of the configuration of d. It must be called with
+// mu held.
+func (s *Server) changed(d *dataset) {
+	s.generation++
+	d.changedAt = time.Now()
+}
+
+// sortedLeases returns the leases ordered by dataset ID. It must be called
+// with mu held.
+func (s *Server) sortedLeases() []*lease {
+	leases := make([]*lease, 0, len(s.leases))
+	for _, l := range s.leases {
+		leases = append(leases, l)
+	}
+	sort.Slice(leases, func(i, j int) bool { return leases[i].datasetID < leases[j].datasetID })
+	return leases
+}
+
+// newDatasetID returns a random (version 4) UUID.
+func newDatasetID() string {
+	var b [16]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
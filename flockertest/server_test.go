@@ -0,0 +1,166 @@
+package flockertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// do sends a request to the fake and decodes the JSON answer into v, when
+// it is not nil.
+func do(assert *assert.Assertions, s *Server, method, path string, body interface{}, header http.Header, v interface{}) *http.Response {
+	var b bytes.Buffer
+	if body != nil {
+		assert.NoError(json.NewEncoder(&b).Encode(body))
+	}
+	req, err := http.NewRequest(method, s.URL+path, &b)
+	assert.NoError(err)
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+
+	resp, err := s.Client().Do(req)
+	assert.NoError(err)
+	defer resp.Body.Close()
+	if v != nil {
+		assert.NoError(json.NewDecoder(resp.Body).Decode(v))
+	}
+	return resp
+}
+
+func TestServerNodesAndVersion(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer(WithVersion("1.4.0"))
+	defer s.Close()
+	s.AddNode(Node{UUID: "n2", Host: "10.0.0.2"})
+
+	var nodes []Node
+	do(assert, s, "GET", "/v1/state/nodes", nil, nil, &nodes)
+	assert.Equal([]Node{{UUID: DefaultNodeUUID, Host: DefaultNodeHost}, {UUID: "n2", Host: "10.0.0.2"}}, nodes)
+
+	var version map[string]string
+	do(assert, s, "GET", "/v1/version", nil, nil, &version)
+	assert.Equal("1.4.0", version["flocker"])
+
+	resp := do(assert, s, "GET", "/v1/nope", nil, nil, nil)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServerDatasets(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	var c configuration
+	resp := do(assert, s, "POST", datasetsPath, map[string]interface{}{"primary": "n1", "maximum_size": 1024}, nil, &c)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+	assert.Len(c.DatasetID, 36)
+	assert.Equal("n1", c.Primary)
+
+	var states []state
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Equal([]state{{DatasetID: c.DatasetID, Primary: "n1", MaximumSize: 1024, Path: "/flocker/" + c.DatasetID}}, states)
+
+	resp = do(assert, s, "POST", datasetsPath+"/"+c.DatasetID, map[string]interface{}{"primary": "n2"}, nil, &c)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("n2", c.Primary)
+
+	resp = do(assert, s, "DELETE", datasetsPath+"/"+c.DatasetID, nil, nil, &c)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.True(c.Deleted)
+
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Empty(states)
+
+	// Deleted datasets are still configured, and keep their ID
+	var configurations []configuration
+	do(assert, s, "GET", datasetsPath, nil, nil, &configurations)
+	assert.Len(configurations, 1)
+
+	resp = do(assert, s, "POST", datasetsPath, map[string]interface{}{"primary": "n1", "dataset_id": c.DatasetID}, nil, nil)
+	assert.Equal(http.StatusConflict, resp.StatusCode)
+
+	resp = do(assert, s, "DELETE", datasetsPath+"/"+c.DatasetID, nil, nil, nil)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	resp = do(assert, s, "POST", datasetsPath, map[string]interface{}{"maximum_size": 1024}, nil, nil)
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestServerConfigurationTag(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	resp := do(assert, s, "GET", datasetsPath, nil, nil, nil)
+	tag := resp.Header.Get(configurationTagHeader)
+	assert.NotEmpty(tag)
+
+	body := map[string]interface{}{"primary": "n1"}
+	stale := http.Header{ifConfigurationMatchesHeader: {"stale"}}
+	resp = do(assert, s, "POST", datasetsPath, body, stale, nil)
+	assert.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+
+	current := http.Header{ifConfigurationMatchesHeader: {tag}}
+	resp = do(assert, s, "POST", datasetsPath, body, current, nil)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+
+	// The tag changed with the creation
+	resp = do(assert, s, "POST", datasetsPath, body, current, nil)
+	assert.Equal(http.StatusPreconditionFailed, resp.StatusCode)
+}
+
+func TestServerConvergenceDelay(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer(WithConvergenceDelay(time.Hour))
+	defer s.Close()
+
+	do(assert, s, "POST", datasetsPath, map[string]interface{}{"primary": "n1"}, nil, nil)
+
+	var states []state
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Empty(states)
+
+	s.Converge()
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Len(states, 1)
+}
+
+func TestServerLeases(t *testing.T) {
+	assert := assert.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	var c configuration
+	do(assert, s, "POST", datasetsPath, map[string]interface{}{"primary": "n1"}, nil, &c)
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, nil)
+
+	resp := do(assert, s, "POST", leasesPath, map[string]interface{}{"dataset_id": c.DatasetID, "node_uuid": "n1", "expires": 60}, nil, nil)
+	assert.Equal(http.StatusCreated, resp.StatusCode)
+	resp = do(assert, s, "POST", leasesPath, map[string]interface{}{"dataset_id": c.DatasetID, "node_uuid": "n1"}, nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	resp = do(assert, s, "POST", leasesPath, map[string]interface{}{"dataset_id": c.DatasetID, "node_uuid": "n2"}, nil, nil)
+	assert.Equal(http.StatusConflict, resp.StatusCode)
+
+	var leases []leasePayload
+	do(assert, s, "GET", leasesPath, nil, nil, &leases)
+	assert.Equal([]leasePayload{{DatasetID: c.DatasetID, NodeUUID: "n1"}}, leases)
+
+	// The lease holder keeps the dataset
+	do(assert, s, "POST", datasetsPath+"/"+c.DatasetID, map[string]interface{}{"primary": "n2"}, nil, nil)
+	var states []state
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Equal("n1", states[0].Primary)
+
+	resp = do(assert, s, "DELETE", leasesPath+"/"+c.DatasetID, nil, nil, nil)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	resp = do(assert, s, "DELETE", leasesPath+"/"+c.DatasetID, nil, nil, nil)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+
+	do(assert, s, "GET", "/v1/state/datasets", nil, nil, &states)
+	assert.Equal("n2", states[0].Primary)
+}
@@ -0,0 +1,308 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ClusterHQ/flocker-go/flockertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeClient(assert *assert.Assertions, fake *flockertest.Server) *Client {
+	c, err := NewClient(fake.Host(), fake.Port(), flockertest.DefaultNodeHost, "", "", "",
+		WithHTTPClient(fake.Client()),
+		WithPollInterval(10*time.Millisecond),
+		WithWaitTimeout(5*time.Second),
+	)
+	assert.NoError(err)
+	return c
+}
+
+func TestFakeDatasetLifecycle(t *testing.T) {
+	assert := assert.New(t)
+	fake := flockertest.NewServer(
+		flockertest.WithConvergenceDelay(50*time.Millisecond),
+		flockertest.WithNodes(
+			flockertest.Node{UUID: "n1", Host: flockertest.DefaultNodeHost},
+			flockertest.Node{UUID: "n2", Host: "10.0.0.2"},
+		),
+	)
+	defer fake.Close()
+	c := newFakeClient(assert, fake)
+
+	s, err := c.CreateDataset(&CreateDatasetOptions{Metadata: map[string]string{"name": "db"}})
+	assert.NoError(err)
+	assert.Equal("n1", s.Primary)
+
+	_, err = c.CreateDataset(&CreateDatasetOptions{DatasetID: s.DatasetID})
+	assert.Equal(ErrVolumeAlreadyExists, err)
+
+	id, err := c.GetDatasetID("db")
+	assert.NoError(err)
+	assert.Equal(s.DatasetID, id)
+
+	s, err = c.MoveDataset(id, "n2", nil)
+	assert.NoError(err)
+	assert.Equal("n2", s.Primary)
+
+	s, err = c.ResizeDataset(id, 2*defaultVolumeSize)
+	assert.NoError(err)
+	assert.Equal(2*defaultVolumeSize, s.MaximumSize)
+
+	configuration, err := c.UpdateDatasetMetadata(id, MetadataPatch{Set: map[string]string{"tier": "gold"}})
+	assert.NoError(err)
+	assert.Equal(map[string]string{"name": "db", "tier": "gold"}, configuration.Metadata)
+
+	assert.NoError(c.DeleteDatasetAndWait(id, nil))
+	_, err = c.GetDatasetID("db")
+	assert.Equal(ErrConfigurationNotFound, err)
+}
+
+func TestFakeLeases(t *testing.T) {
+	assert := assert.New(t)
+	fake := flockertest.NewServer()
+	defer fake.Close()
+	c := newFakeClient(assert, fake)
+
+	s, err := c.CreateDataset(&CreateDatasetOptions{})
+	assert.NoError(err)
+
+	_, err = c.AcquireLease(s.DatasetID, s.Primary, time.Minute)
+	assert.NoError(err)
+	_, err = c.AcquireLease(s.DatasetID, "someone-else", 0)
+	assert.True(errors.Is(err, ErrConflict))
+
+	leases, err := c.ListLeases()
+	assert.NoError(err)
+	assert.Len(leases, 1)
+
+	assert.NoError(c.ReleaseLease(s.DatasetID))
+	assert.True(errors.Is(c.ReleaseLease(s.DatasetID), ErrNotFound))
+}
+
+func TestFakeConfigurationTag(t *testing.T) {
+	assert := assert.New(t)
+	fake := flockertest.NewServer()
+	defer fake.Close()
+	c := newFakeClient(assert, fake)
+
+	tag, err := c.GetConfigurationTag()
+	assert.NoError(err)
+
+	s, err := c.CreateDataset(&CreateDatasetOptions{Metadata: map[string]string{"name": "db"}})
+	assert.NoError(err)
+
+	_, err = c.CreateDatasetContext(context.Background(), &CreateDatasetOptions{}, IfConfigurationMatches(tag))
+	assert.True(errors.Is(err, ErrPreconditionFailed))
+
+	// The reads give the tag to make changes conditional on
+	_, listTag, err := c.ListDatasetConfigurationsWithTag()
+	assert.NoError(err)
+	assert.NotEqual(tag, listTag)
+
+	id, idTag, err := c.GetDatasetIDWithTag("db")
+	assert.NoError(err)
+	assert.Equal(s.DatasetID, id)
+	assert.Equal(listTag, idTag)
+
+	d, datasetTag, err := c.GetDatasetWithTag(s.DatasetID)
+	assert.NoError(err)
+	assert.Equal(s.DatasetID, d.DatasetID)
+	assert.Equal(listTag, datasetTag)
+
+	_, err = c.UpdateDatasetMetadataContext(context.Background(), s.DatasetID, MetadataPatch{Set: map[string]string{"a": "b"}}, IfConfigurationMatches(datasetTag))
+	assert.NoError(err)
+	err = c.DeleteDatasetContext(context.Background(), s.DatasetID, IfConfigurationMatches(datasetTag))
+	assert.True(errors.Is(err, ErrPreconditionFailed), "the metadata update changed the tag")
+}
+
+// postBarrier holds the dataset creations until n of them are sent, so that
+// clients racing to create a dataset all find none before creating theirs.
+type postBarrier struct {
+	transport http.RoundTripper
+	wg        sync.WaitGroup
+}
+
+func (b *postBarrier) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == "POST" && req.URL.Path == "/v1/configuration/datasets" {
+		b.wg.Done()
+		b.wg.Wait()
+	}
+	return b.transport.RoundTrip(req)
+}
+
+func TestFakeEnsureDatasetConcurrently(t *testing.T) {
+	assert := assert.New(t)
+	fake := flockertest.NewServer(flockertest.WithConvergenceDelay(20 * time.Millisecond))
+	defer fake.Close()
+
+	const clients = 2
+	barrier := &postBarrier{transport: fake.Client().Transport}
+	barrier.wg.Add(clients)
+
+	states := make([]*DatasetState, clients)
+	errs := make([]error, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		c := newBarrierClient(assert, fake, barrier)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			states[i], errs[i] = c.EnsureDataset("db", nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < clients; i++ {
+		if assert.NoError(errs[i]) {
+			assert.Equal(states[0].DatasetID, states[i].DatasetID, "every client gets the same dataset")
+		}
+	}
+
+	// Both datasets were created, only the one with the lowest ID is left
+	c := newFakeClient(assert, fake)
+	configurations, err := c.ListDatasetConfigurations()
+	assert.NoError(err)
+	assert.Len(configurations, clients)
+	ids := datasetIDsByName(configurations)["db"]
+	if assert.Len(ids, 1) && states[0] != nil {
+		assert.Equal(states[0].DatasetID, ids[0])
+		for _, configuration := range configurations {
+			assert.Equal(configuration.DatasetID != ids[0], configuration.Deleted)
+			assert.True(configuration.DatasetID >= ids[0])
+		}
+	}
+}
+
+func TestFakeEnsureDatasetRacingCreateDataset(t *testing.T) {
+	for _, createdID := range []string{
+		"00000000-0000-0000-0000-000000000000",
+		"ffffffff-ffff-ffff-ffff-ffffffffffff",
+	} {
+		assert := assert.New(t)
+		fake := flockertest.NewServer(flockertest.WithConvergenceDelay(20 * time.Millisecond))
+
+		barrier := &postBarrier{transport: fake.Client().Transport}
+		barrier.wg.Add(2)
+		creator := newBarrierClient(assert, fake, barrier)
+		ensurer := newBarrierClient(assert, fake, barrier)
+
+		var (
+			created, ensured     *DatasetState
+			errCreate, errEnsure error
+			wg                   sync.WaitGroup
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			created, errCreate = creator.CreateDataset(&CreateDatasetOptions{
+				DatasetID: createdID,
+				Metadata:  map[string]string{"name": "db"},
+			})
+		}()
+		go func() {
+			defer wg.Done()
+			ensured, errEnsure = ensurer.EnsureDataset("db", nil)
+		}()
+		wg.Wait()
+
+		assert.NoError(errCreate)
+		assert.NoError(errEnsure)
+		if created == nil || ensured == nil {
+			fake.Close()
+			continue
+		}
+
+		// The dataset CreateDataset reported is never deleted by EnsureDataset
+		configurations, err := newFakeClient(assert, fake).ListDatasetConfigurations()
+		assert.NoError(err)
+		assert.Len(configurations, 2)
+		ids := datasetIDsByName(configurations)["db"]
+		assert.Contains(ids, created.DatasetID)
+		assert.Equal(ids[0], ensured.DatasetID, "EnsureDataset adopts the lowest ID")
+		for _, configuration := range configurations {
+			ours := configuration.DatasetID == created.DatasetID || configuration.DatasetID == ids[0]
+			assert.Equal(!ours, configuration.Deleted, configuration.DatasetID)
+		}
+		fake.Close()
+	}
+}
+
+// newBarrierClient returns a client of fake sending its requests through
+// barrier.
+func newBarrierClient(assert *assert.Assertions, fake *flockertest.Server, barrier *postBarrier) *Client {
+	c, err := NewClient(fake.Host(), fake.Port(), flockertest.DefaultNodeHost, "", "", "",
+		WithHTTPClient(&http.Client{Transport: barrier}),
+		WithPollInterval(5*time.Millisecond),
+		WithWaitTimeout(5*time.Second),
+	)
+	assert.NoError(err)
+	return c
+}
+
+func TestFakeCreateDatasetNeverConverges(t *testing.T) {
+	assert := assert.New(t)
+	fake := flockertest.NewServer()
+	defer fake.Close()
+	fake.NeverConverge(flockertest.AnyDataset)
+
+	c := newFakeClient(assert, fake)
+	c.waitTimeout = 100 * time.Millisecond
+
+	_, err := c.CreateDataset(&CreateDatasetOptions{})
+	assert.True(errors.Is(err, ErrTimeout))
+
+	// The dataset which never got ready was cleaned up
+	configurations, err := c.ListDatasetConfigurations()
+	assert.NoError(err)
+	assert.Len(configurations, 1)
+	assert.True(configurations[0].Deleted)
+}
+
+func TestFakeMoveStalls(t *testing.T) {
+	assert := assert.New(t)
+	fake := flockertest.NewServer()
+	defer fake.Close()
+
+	c := newFakeClient(assert, fake)
+	s, err := c.CreateDataset(&CreateDatasetOptions{})
+	assert.NoError(err)
+
+	fake.StallMoves(s.DatasetID)
+	_, err = c.MoveDataset(s.DatasetID, "n2", &MoveOptions{Timeout: 100 * time.Millisecond, RevertOnTimeout: true})
+	assert.True(errors.Is(err, ErrTimeout))
+
+	configurations, err := c.ListDatasetConfigurations()
+	assert.NoError(err)
+	assert.Equal(s.Primary, configurations[0].Primary)
+}
+
+func TestFakeRetriesFaults(t *testing.T) {
+	assert := assert.New(t)
+	fake := flockertest.NewServer()
+	defer fake.Close()
+
+	c := newFakeClient(assert, fake)
+	c.retry.Backoff = ConstantBackoff(time.Millisecond)
+
+	fake.InjectFaults("GET", "/v1/state/nodes", flockertest.Fault{Drop: true}, flockertest.Fault{Status: http.StatusServiceUnavailable})
+	nodes, err := c.ListNodes()
+	assert.NoError(err)
+	assert.Len(nodes, 1)
+
+	// Changes are not retried once they may have been made
+	fake.InjectFaults("POST", "/v1/configuration/datasets", flockertest.Fault{Status: http.StatusServiceUnavailable})
+	_, err = c.CreateDataset(&CreateDatasetOptions{})
+	var errAPI *APIError
+	assert.True(errors.As(err, &errAPI))
+	assert.Equal(http.StatusServiceUnavailable, errAPI.StatusCode)
+
+	fake.InjectFaults("GET", "/v1/configuration/datasets", flockertest.Fault{Malformed: true})
+	_, err = c.ListDatasetConfigurations()
+	assert.Error(err)
+}
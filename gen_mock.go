@@ -0,0 +1,238 @@
+//go:build ignore
+
+// gen_mock generates mock_generated.go, the MockClient and RecordingClient
+// methods, from the Clientable and ExtendedClientable interfaces in client.go.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"strings"
+	"text/template"
+)
+
+type param struct {
+	Name string
+	Type string
+}
+
+type method struct {
+	Name string
+	// Extended is set for the methods of ExtendedClientable.
+	Extended bool
+	// Params are the parameters of the method, Results its results but the
+	// trailing error.
+	Params  []param
+	Results []param
+}
+
+// Args lists the parameters as call arguments.
+func (m method) Args() string {
+	var names []string
+	for _, p := range m.Params {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Signature is the parameters and named results of the method.
+func (m method) Signature() string {
+	var params, results []string
+	for _, p := range m.Params {
+		params = append(params, p.Name+" "+p.Type)
+	}
+	for _, r := range m.Results {
+		results = append(results, r.Name+" "+r.Type)
+	}
+	results = append(results, "err error")
+	return "(" + strings.Join(params, ", ") + ") (" + strings.Join(results, ", ") + ")"
+}
+
+// Returned lists the named results, the error included.
+func (m method) Returned() string {
+	var names []string
+	for _, r := range m.Results {
+		names = append(names, r.Name)
+	}
+	return strings.Join(append(names, "err"), ", ")
+}
+
+const source = `// Code generated by gen_mock.go from the Clientable and ExtendedClientable interfaces; DO NOT EDIT.
+
+package flocker
+
+{{if .Time}}import "time"
+{{end}}
+/*
+MockClient is an ExtendedClientable for unit tests. Every method calls the stub
+function of the same name, e.g. CreateDatasetFunc for CreateDataset, or fails
+with an error matching ErrNotStubbed if it is nil. Every call is recorded, see
+Calls.
+
+	m := &flocker.MockClient{
+		GetDatasetIDFunc: func(name string) (string, error) { return "d1", nil },
+	}
+
+The stub functions must be set before the MockClient is used, it is then safe
+for concurrent use.
+*/
+type MockClient struct {
+{{- range .Methods}}
+	{{.Name}}Func func{{.Signature}}
+{{- end}}
+
+	callLog
+}
+{{range .Methods}}
+// {{.Name}} calls {{.Name}}Func and records the call.
+func (m *MockClient) {{.Name}}{{.Signature}} {
+	if m.{{.Name}}Func != nil {
+		{{.Returned}} = m.{{.Name}}Func({{.Args}})
+	} else {
+		err = notStubbed("{{.Name}}")
+	}
+	m.record("{{.Name}}", []interface{}{ {{- .Args -}} }, []interface{}{ {{- range $i, $r := .Results}}{{if $i}}, {{end}}{{$r.Name}}{{end -}} }, err)
+	return {{.Returned}}
+}
+{{end}}
+{{- range .Methods}}
+{{- if .Extended}}
+// {{.Name}} calls the wrapped client, which must be an ExtendedClientable, and
+// records the call.
+func (r *RecordingClient) {{.Name}}{{.Signature}} {
+	var c ExtendedClientable
+	if c, err = r.extended("{{.Name}}"); err == nil {
+		{{.Returned}} = c.{{.Name}}({{.Args}})
+	}
+	r.record(
+{{- else}}
+// {{.Name}} calls the wrapped Clientable and records the call.
+func (r *RecordingClient) {{.Name}}{{.Signature}} {
+	{{.Returned}} = r.client.{{.Name}}({{.Args}})
+	r.record(
+{{- end}}"{{.Name}}", []interface{}{ {{- .Args -}} }, []interface{}{ {{- range $i, $r := .Results}}{{if $i}}, {{end}}{{$r.Name}}{{end -}} }, err)
+	return {{.Returned}}
+}
+{{end}}
+// replayMock returns a MockClient whose stub functions answer with the calls
+// of p.
+func replayMock(p *replayer) *MockClient {
+	return &MockClient{
+{{- range .Methods}}
+		{{.Name}}Func: func{{.Signature}} {
+			c, errNext := p.next("{{.Name}}")
+			if errNext != nil {
+				err = errNext
+				return {{.Returned}}
+			}
+			{{- range $i, $r := .Results}}
+			{{$r.Name}}, _ = c.Results[{{$i}}].({{$r.Type}})
+			{{- end}}
+			err = c.Err
+			return {{.Returned}}
+		},
+{{- end}}
+	}
+}
+`
+
+func main() {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "client.go", nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	methods := append(interfaceMethods(fset, f, "Clientable"), interfaceMethods(fset, f, "ExtendedClientable")...)
+
+	data := struct {
+		Methods []method
+		Time    bool
+	}{Methods: methods}
+	for _, m := range methods {
+		for _, p := range append(m.Params, m.Results...) {
+			data.Time = data.Time || strings.Contains(p.Type, "time.")
+		}
+	}
+
+	var b bytes.Buffer
+	if err := template.Must(template.New("mock").Parse(source)).Execute(&b, data); err != nil {
+		log.Fatal(err)
+	}
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("%s\n%s", err, b.Bytes())
+	}
+	if err := ioutil.WriteFile("mock_generated.go", out, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// interfaceMethods returns the methods declared by the named interface, not
+// the ones it embeds.
+func interfaceMethods(fset *token.FileSet, f *ast.File, name string) []method {
+	var methods []method
+	found := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok || spec.Name.Name != name {
+			return true
+		}
+		found = true
+		for _, field := range spec.Type.(*ast.InterfaceType).Methods.List {
+			if len(field.Names) == 0 {
+				continue
+			}
+			m := newMethod(fset, name, field)
+			m.Extended = name == "ExtendedClientable"
+			methods = append(methods, m)
+		}
+		return false
+	})
+	if !found {
+		log.Fatalf("%s not found in client.go", name)
+	}
+	return methods
+}
+
+func newMethod(fset *token.FileSet, iface string, field *ast.Field) method {
+	m := method{Name: field.Names[0].Name}
+	fn := field.Type.(*ast.FuncType)
+
+	for _, p := range fn.Params.List {
+		for _, name := range p.Names {
+			m.Params = append(m.Params, param{Name: name.Name, Type: typeString(fset, p.Type)})
+		}
+	}
+
+	var results []string
+	for _, r := range fn.Results.List {
+		n := len(r.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			results = append(results, typeString(fset, r.Type))
+		}
+	}
+	if len(results) == 0 || results[len(results)-1] != "error" {
+		log.Fatalf("%s.%s must return an error last", iface, m.Name)
+	}
+	for i, t := range results[:len(results)-1] {
+		m.Results = append(m.Results, param{Name: fmt.Sprintf("r%d", i), Type: t})
+	}
+	return m
+}
+
+func typeString(fset *token.FileSet, expr ast.Expr) string {
+	var b bytes.Buffer
+	printer.Fprint(&b, fset, expr)
+	return b.String()
+}
@@ -0,0 +1,147 @@
+package flocker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Lease stops a dataset from being moved or deleted while it is in use on a
+// node. Leases need CapabilityLeases, calls against an older control service
+// fail with an error matching ErrUnsupported.
+type Lease struct {
+	DatasetID string
+	NodeUUID  string
+	// Expires is how long the lease has left before it lapses, zero when the
+	// lease never expires.
+	Expires time.Duration
+}
+
+type leasePayload struct {
+	DatasetID string   `json:"dataset_id"`
+	NodeUUID  string   `json:"node_uuid"`
+	Expires   *float64 `json:"expires"`
+}
+
+func (p leasePayload) lease() Lease {
+	l := Lease{DatasetID: p.DatasetID, NodeUUID: p.NodeUUID}
+	if p.Expires != nil {
+		l.Expires = time.Duration(*p.Expires * float64(time.Second))
+	}
+	return l
+}
+
+// AcquireLease takes a lease on datasetID for the node nodeUUID, which then
+// cannot be moved or deleted until the lease is released or expires. A zero
+// expires means the lease never expires.
+//
+// Acquiring a lease the node already holds renews it, a lease held by another
+// node makes it fail with an error matching ErrConflict.
+func (c Client) AcquireLease(datasetID, nodeUUID string, expires time.Duration) (*Lease, error) {
+	return c.AcquireLeaseContext(context.Background(), datasetID, nodeUUID, expires)
+}
+
+// AcquireLeaseContext is like AcquireLease but the request is bound to ctx.
+func (c Client) AcquireLeaseContext(ctx context.Context, datasetID, nodeUUID string, expires time.Duration) (*Lease, error) {
+	if err := c.require(ctx, CapabilityLeases); err != nil {
+		return nil, err
+	}
+
+	payload := leasePayload{
+		DatasetID: datasetID,
+		NodeUUID:  nodeUUID,
+	}
+	if expires > 0 {
+		seconds := expires.Seconds()
+		payload.Expires = &seconds
+	}
+
+	resp, err := c.post(ctx, c.getURL("configuration/leases"), payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, newAPIError(resp)
+	}
+
+	var p leasePayload
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return nil, err
+	}
+	l := p.lease()
+	return &l, nil
+}
+
+// RenewLease extends a lease already held by nodeUUID, it is the same as
+// acquiring it again.
+func (c Client) RenewLease(datasetID, nodeUUID string, expires time.Duration) (*Lease, error) {
+	return c.AcquireLeaseContext(context.Background(), datasetID, nodeUUID, expires)
+}
+
+// RenewLeaseContext is like RenewLease but the request is bound to ctx.
+func (c Client) RenewLeaseContext(ctx context.Context, datasetID, nodeUUID string, expires time.Duration) (*Lease, error) {
+	return c.AcquireLeaseContext(ctx, datasetID, nodeUUID, expires)
+}
+
+// ReleaseLease releases the lease on datasetID, whatever node holds it.
+// Releasing a lease that does not exist fails with an error matching
+// ErrNotFound.
+func (c Client) ReleaseLease(datasetID string) error {
+	return c.ReleaseLeaseContext(context.Background(), datasetID)
+}
+
+// ReleaseLeaseContext is like ReleaseLease but the request is bound to ctx.
+func (c Client) ReleaseLeaseContext(ctx context.Context, datasetID string) error {
+	if err := c.require(ctx, CapabilityLeases); err != nil {
+		return err
+	}
+
+	url := c.getURL(fmt.Sprintf("configuration/leases/%s", datasetID))
+	resp, err := c.delete(ctx, url, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+
+	return nil
+}
+
+// ListLeases returns every lease known by the control service.
+func (c Client) ListLeases() ([]Lease, error) {
+	return c.ListLeasesContext(context.Background())
+}
+
+// ListLeasesContext is like ListLeases but the request is bound to ctx.
+func (c Client) ListLeasesContext(ctx context.Context) ([]Lease, error) {
+	if err := c.require(ctx, CapabilityLeases); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(ctx, c.getURL("configuration/leases"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, newAPIError(resp)
+	}
+
+	var payloads []leasePayload
+	if err := json.NewDecoder(resp.Body).Decode(&payloads); err != nil {
+		return nil, err
+	}
+
+	leases := make([]Lease, 0, len(payloads))
+	for _, p := range payloads {
+		leases = append(leases, p.lease())
+	}
+	return leases, nil
+}
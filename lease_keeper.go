@@ -0,0 +1,261 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// releaseTimeout bounds how long a LeaseKeeper waits for the control service
+// while releasing its leases on shutdown.
+const releaseTimeout = 30 * time.Second
+
+// minRenewInterval bounds how often a LeaseKeeper renews its leases, however
+// short they are.
+const minRenewInterval = 10 * time.Millisecond
+
+var errLeaseKeeperStopped = errors.New("The lease keeper is stopped")
+
+// LeaseError reports a lease a LeaseKeeper failed to renew or release.
+type LeaseError struct {
+	DatasetID string
+	Err       error
+}
+
+func (e *LeaseError) Error() string {
+	return fmt.Sprintf("Lease on dataset %s: %s", e.DatasetID, e.Err)
+}
+
+func (e *LeaseError) Unwrap() error { return e.Err }
+
+// leaseContextClient is implemented by the clients whose lease calls can be
+// bound to a context, such as *Client. A LeaseKeeper given another Clientable
+// uses the plain calls.
+type leaseContextClient interface {
+	AcquireLeaseContext(ctx context.Context, datasetID, nodeUUID string, expires time.Duration) (*Lease, error)
+	ReleaseLeaseContext(ctx context.Context, datasetID string) error
+}
+
+/*
+LeaseKeeper holds leases for a node, renewing them in the background so they
+never lapse while the process is healthy. If the process dies the leases stop
+being renewed and lapse once they expire.
+
+Every lease is released when the keeper is stopped, either with Stop or by
+cancelling the context given to NewLeaseKeeper.
+*/
+type LeaseKeeper struct {
+	client   Clientable
+	clock    Clock
+	nodeUUID string
+	expires  time.Duration
+	interval time.Duration
+
+	// opMu serializes the requests to the control service, so a renewal
+	// never brings back a lease that is being released.
+	opMu sync.Mutex
+
+	mu      sync.Mutex
+	leases  map[string]struct{}
+	stopped bool
+
+	errs    chan error
+	cancel  context.CancelFunc
+	done    chan struct{}
+	stopErr error
+}
+
+/*
+NewLeaseKeeper starts a LeaseKeeper for the node nodeUUID. The leases it
+takes expire after expires and are renewed every third of it, but no more
+often than every 10ms. A zero or negative expires takes leases which never
+expire, as AcquireLease does, and they are never renewed.
+
+The keeper runs until Stop is called or ctx is done.
+*/
+func NewLeaseKeeper(ctx context.Context, client Clientable, nodeUUID string, expires time.Duration) *LeaseKeeper {
+	var clock Clock = realClock{}
+	if c, ok := client.(*Client); ok {
+		clock = c.clock
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	k := &LeaseKeeper{
+		client:   client,
+		clock:    clock,
+		nodeUUID: nodeUUID,
+		expires:  expires,
+		interval: renewInterval(expires),
+		leases:   make(map[string]struct{}),
+		errs:     make(chan error, 16),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go k.run(ctx)
+	return k
+}
+
+// renewInterval returns how often leases expiring after expires are renewed,
+// zero if they never expire.
+func renewInterval(expires time.Duration) time.Duration {
+	if expires <= 0 {
+		return 0
+	}
+	if interval := expires / 3; interval > minRenewInterval {
+		return interval
+	}
+	return minRenewInterval
+}
+
+// Errors returns the channel where renewal and release failures are reported
+// as *LeaseError. Failures are dropped if nobody reads them fast enough. The
+// channel is closed once the keeper is stopped.
+func (k *LeaseKeeper) Errors() <-chan error {
+	return k.errs
+}
+
+// Add acquires the lease on datasetID and keeps renewing it until it is
+// released.
+func (k *LeaseKeeper) Add(ctx context.Context, datasetID string) error {
+	k.opMu.Lock()
+	defer k.opMu.Unlock()
+
+	k.mu.Lock()
+	stopped := k.stopped
+	k.mu.Unlock()
+	if stopped {
+		return errLeaseKeeperStopped
+	}
+
+	if _, err := k.acquire(ctx, datasetID); err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.leases[datasetID] = struct{}{}
+	k.mu.Unlock()
+	return nil
+}
+
+// Release stops renewing the lease on datasetID and releases it.
+func (k *LeaseKeeper) Release(ctx context.Context, datasetID string) error {
+	k.opMu.Lock()
+	defer k.opMu.Unlock()
+
+	k.mu.Lock()
+	delete(k.leases, datasetID)
+	k.mu.Unlock()
+
+	return k.release(ctx, datasetID)
+}
+
+// Leases returns the IDs of the datasets whose lease is being kept.
+func (k *LeaseKeeper) Leases() []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	ids := make([]string, 0, len(k.leases))
+	for id := range k.leases {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Stop stops renewing the leases and releases all of them, returning the
+// errors found while releasing.
+func (k *LeaseKeeper) Stop() error {
+	k.cancel()
+	<-k.done
+	return k.stopErr
+}
+
+// Done is closed once the keeper has stopped and released its leases.
+func (k *LeaseKeeper) Done() <-chan struct{} {
+	return k.done
+}
+
+func (k *LeaseKeeper) run(ctx context.Context) {
+	defer close(k.done)
+	defer close(k.errs)
+
+	// Leases which never expire need no renewal
+	var renew <-chan time.Time
+	for {
+		if k.interval > 0 {
+			renew = k.clock.After(k.interval)
+		}
+		select {
+		case <-ctx.Done():
+			k.stopErr = k.releaseAll()
+			return
+		case <-renew:
+			k.renewAll(ctx)
+		}
+	}
+}
+
+// renewAll renews every lease being kept, reporting the failures.
+func (k *LeaseKeeper) renewAll(ctx context.Context) {
+	k.opMu.Lock()
+	defer k.opMu.Unlock()
+
+	for _, id := range k.Leases() {
+		if _, err := k.acquire(ctx, id); err != nil && ctx.Err() == nil {
+			k.report(&LeaseError{DatasetID: id, Err: err})
+		}
+	}
+}
+
+// releaseAll releases every lease being kept, it is only called once the
+// keeper's context is done, so it uses its own.
+func (k *LeaseKeeper) releaseAll() error {
+	k.opMu.Lock()
+	defer k.opMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, id := range k.Leases() {
+		if err := k.release(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+			leaseErr := &LeaseError{DatasetID: id, Err: err}
+			k.report(leaseErr)
+			errs = append(errs, leaseErr)
+		}
+	}
+
+	k.mu.Lock()
+	k.leases = make(map[string]struct{})
+	k.stopped = true
+	k.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// acquire acquires or renews the lease on datasetID, bound to ctx if the
+// client supports it.
+func (k *LeaseKeeper) acquire(ctx context.Context, datasetID string) (*Lease, error) {
+	if c, ok := k.client.(leaseContextClient); ok {
+		return c.AcquireLeaseContext(ctx, datasetID, k.nodeUUID, k.expires)
+	}
+	return k.client.AcquireLease(datasetID, k.nodeUUID, k.expires)
+}
+
+// release releases the lease on datasetID, bound to ctx if the client
+// supports it.
+func (k *LeaseKeeper) release(ctx context.Context, datasetID string) error {
+	if c, ok := k.client.(leaseContextClient); ok {
+		return c.ReleaseLeaseContext(ctx, datasetID)
+	}
+	return k.client.ReleaseLease(datasetID)
+}
+
+// report sends err to the Errors channel unless it is full.
+func (k *LeaseKeeper) report(err error) {
+	select {
+	case k.errs <- err:
+	default:
+	}
+}
@@ -0,0 +1,193 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// leaseServer is a minimal leases endpoint that counts the acquisitions.
+type leaseServer struct {
+	mu       sync.Mutex
+	leases   map[string]bool
+	acquired int
+	conflict bool
+}
+
+func (s *leaseServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "POST":
+		if s.conflict {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		s.acquired++
+		s.leases["datasetID"] = true
+		w.Write([]byte(`{"dataset_id": "datasetID", "node_uuid": "node1", "expires": 1}`))
+	case "DELETE":
+		id := strings.TrimPrefix(r.URL.Path, "/v1/configuration/leases/")
+		if !s.leases[id] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.leases, id)
+		w.Write([]byte(`{"dataset_id": "datasetID", "node_uuid": "node1", "expires": 1}`))
+	}
+}
+
+func (s *leaseServer) state() (acquired int, held bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acquired, s.leases["datasetID"]
+}
+
+func TestLeaseKeeperRenewsUntilStopped(t *testing.T) {
+	assert := assert.New(t)
+	s := &leaseServer{leases: map[string]bool{}}
+
+	c, done := newHandlerTestClient(assert, leaseHandler(s.ServeHTTP))
+	defer done()
+
+	k := NewLeaseKeeper(context.Background(), c, "node1", 30*time.Millisecond)
+	assert.NoError(k.Add(context.Background(), "datasetID"))
+	assert.Equal([]string{"datasetID"}, k.Leases())
+
+	assert.Eventually(func() bool {
+		acquired, _ := s.state()
+		return acquired >= 3
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(k.Stop())
+	_, held := s.state()
+	assert.False(held, "lease released on Stop")
+	assert.Empty(k.Leases())
+	assert.Equal(errLeaseKeeperStopped, k.Add(context.Background(), "datasetID"))
+}
+
+func TestLeaseKeeperReleasesOnCancel(t *testing.T) {
+	assert := assert.New(t)
+	s := &leaseServer{leases: map[string]bool{}}
+
+	c, done := newHandlerTestClient(assert, leaseHandler(s.ServeHTTP))
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k := NewLeaseKeeper(ctx, c, "node1", time.Minute)
+	assert.NoError(k.Add(context.Background(), "datasetID"))
+
+	cancel()
+	<-k.Done()
+
+	_, held := s.state()
+	assert.False(held, "lease released on cancellation")
+	_, open := <-k.Errors()
+	assert.False(open, "errors channel closed")
+}
+
+func TestLeaseKeeperReportsRenewalFailures(t *testing.T) {
+	assert := assert.New(t)
+	s := &leaseServer{leases: map[string]bool{}}
+
+	c, done := newHandlerTestClient(assert, leaseHandler(s.ServeHTTP))
+	defer done()
+
+	k := NewLeaseKeeper(context.Background(), c, "node1", 30*time.Millisecond)
+	defer k.Stop()
+	assert.NoError(k.Add(context.Background(), "datasetID"))
+
+	s.mu.Lock()
+	s.conflict = true
+	s.mu.Unlock()
+
+	select {
+	case err := <-k.Errors():
+		var leaseErr *LeaseError
+		if assert.True(errors.As(err, &leaseErr)) {
+			assert.Equal("datasetID", leaseErr.DatasetID)
+		}
+		assert.True(errors.Is(err, ErrConflict))
+	case <-time.After(time.Second):
+		t.Error("renewal failure was not reported")
+	}
+}
+
+func TestLeaseKeeperRelease(t *testing.T) {
+	assert := assert.New(t)
+	s := &leaseServer{leases: map[string]bool{}}
+
+	c, done := newHandlerTestClient(assert, leaseHandler(s.ServeHTTP))
+	defer done()
+
+	k := NewLeaseKeeper(context.Background(), c, "node1", time.Minute)
+	assert.NoError(k.Add(context.Background(), "datasetID"))
+	assert.NoError(k.Release(context.Background(), "datasetID"))
+	assert.Empty(k.Leases())
+
+	_, held := s.state()
+	assert.False(held)
+	assert.NoError(k.Stop())
+}
+
+func TestLeaseKeeperRenewInterval(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(20*time.Second, renewInterval(time.Minute))
+	assert.Equal(minRenewInterval, renewInterval(3*time.Nanosecond))
+	assert.Equal(minRenewInterval, renewInterval(time.Nanosecond))
+	assert.Equal(time.Duration(0), renewInterval(0))
+	assert.Equal(time.Duration(0), renewInterval(-time.Second))
+}
+
+func TestLeaseKeeperNeverExpiring(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &MockClient{
+		AcquireLeaseFunc: func(datasetID, nodeUUID string, expires time.Duration) (*Lease, error) {
+			return &Lease{DatasetID: datasetID, NodeUUID: nodeUUID}, nil
+		},
+		ReleaseLeaseFunc: func(datasetID string) error {
+			return nil
+		},
+	}
+
+	for _, expires := range []time.Duration{0, -time.Second} {
+		m.Reset()
+		k := NewLeaseKeeper(context.Background(), m, "node1", expires)
+		assert.NoError(k.Add(context.Background(), "datasetID"))
+
+		time.Sleep(5 * minRenewInterval)
+		assert.Len(m.CallsTo("AcquireLease"), 1, "lease which never expires is not renewed")
+
+		assert.NoError(k.Stop())
+		assert.Len(m.CallsTo("ReleaseLease"), 1)
+	}
+}
+
+func TestLeaseKeeperShortExpiry(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &MockClient{
+		AcquireLeaseFunc: func(datasetID, nodeUUID string, expires time.Duration) (*Lease, error) {
+			return &Lease{DatasetID: datasetID, NodeUUID: nodeUUID, Expires: expires}, nil
+		},
+		ReleaseLeaseFunc: func(datasetID string) error {
+			return nil
+		},
+	}
+
+	k := NewLeaseKeeper(context.Background(), m, "node1", time.Nanosecond)
+	assert.NoError(k.Add(context.Background(), "datasetID"))
+
+	time.Sleep(5 * minRenewInterval)
+	assert.NoError(k.Stop())
+	assert.True(len(m.CallsTo("AcquireLease")) <= 10, "renewals are bounded by minRenewInterval")
+}
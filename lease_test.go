@@ -0,0 +1,116 @@
+package flocker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// leaseHandler answers with handler, but for the version of the control
+// service which is one with leases.
+func leaseHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/version" {
+			w.Write([]byte(`{"flocker": "1.15.0"}`))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func TestAcquireLease(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, leaseHandler(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("POST", r.Method)
+		assert.Equal("/v1/configuration/leases", r.URL.Path)
+
+		var p leasePayload
+		assert.NoError(json.NewDecoder(r.Body).Decode(&p))
+		assert.Equal("datasetID", p.DatasetID)
+		assert.Equal("node1", p.NodeUUID)
+		if assert.NotNil(p.Expires) {
+			assert.Equal(60.0, *p.Expires)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"dataset_id": "datasetID", "node_uuid": "node1", "expires": 59.5}`))
+	}))
+	defer done()
+
+	l, err := c.AcquireLease("datasetID", "node1", time.Minute)
+	assert.NoError(err)
+	assert.Equal(Lease{DatasetID: "datasetID", NodeUUID: "node1", Expires: 59500 * time.Millisecond}, *l)
+}
+
+func TestAcquireLeaseWithoutExpiration(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, leaseHandler(func(w http.ResponseWriter, r *http.Request) {
+		var p map[string]interface{}
+		assert.NoError(json.NewDecoder(r.Body).Decode(&p))
+		assert.Contains(p, "expires")
+		assert.Nil(p["expires"])
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"dataset_id": "datasetID", "node_uuid": "node1", "expires": null}`))
+	}))
+	defer done()
+
+	l, err := c.AcquireLease("datasetID", "node1", 0)
+	assert.NoError(err)
+	assert.Equal(time.Duration(0), l.Expires)
+}
+
+func TestAcquireLeaseHeldByOtherNode(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, leaseHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"description": "Lease already held."}`))
+	}))
+	defer done()
+
+	_, err := c.AcquireLease("datasetID", "node2", time.Minute)
+	assert.True(errors.Is(err, ErrConflict))
+}
+
+func TestReleaseLease(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, leaseHandler(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("DELETE", r.Method)
+		if r.URL.Path == "/v1/configuration/leases/datasetID" {
+			w.Write([]byte(`{"dataset_id": "datasetID", "node_uuid": "node1", "expires": null}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"description": "Lease not found."}`))
+	}))
+	defer done()
+
+	assert.NoError(c.ReleaseLease("datasetID"))
+	assert.True(errors.Is(c.ReleaseLease("other"), ErrNotFound))
+}
+
+func TestListLeases(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, leaseHandler(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("GET", r.Method)
+		assert.Equal("/v1/configuration/leases", r.URL.Path)
+		w.Write([]byte(`[{"dataset_id": "d1", "node_uuid": "n1", "expires": 10}, {"dataset_id": "d2", "node_uuid": "n2", "expires": null}]`))
+	}))
+	defer done()
+
+	leases, err := c.ListLeases()
+	assert.NoError(err)
+	assert.Equal([]Lease{
+		{DatasetID: "d1", NodeUUID: "n1", Expires: 10 * time.Second},
+		{DatasetID: "d2", NodeUUID: "n2"},
+	}, leases)
+}
@@ -0,0 +1,168 @@
+package flocker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	maxMetadataKeyLength   = 256
+	maxMetadataValueLength = 256
+
+	// metadataUpdateAttempts is how many times a metadata update is tried
+	// when the configuration keeps changing under it.
+	metadataUpdateAttempts = 5
+)
+
+// MetadataPatch is a change to the metadata of a dataset.
+type MetadataPatch struct {
+	// Set adds these keys, overwriting the ones that already exist.
+	Set map[string]string
+	// Remove deletes these keys, it is fine if they do not exist.
+	Remove []string
+	// Replace drops every key that is not in Set.
+	Replace bool
+}
+
+// Validate checks that the patch can be applied and results in valid
+// metadata.
+func (p MetadataPatch) Validate() error {
+	if err := ValidateMetadata(p.Set); err != nil {
+		return err
+	}
+	if p.Replace && len(p.Remove) > 0 {
+		return errors.New("Invalid metadata patch: keys cannot be removed when replacing the metadata")
+	}
+	for _, key := range p.Remove {
+		if _, ok := p.Set[key]; ok {
+			return fmt.Errorf("Invalid metadata patch: key '%s' is both set and removed", key)
+		}
+	}
+	return nil
+}
+
+// Apply returns the result of applying the patch to metadata, which is left
+// untouched.
+func (p MetadataPatch) Apply(metadata map[string]string) map[string]string {
+	result := make(map[string]string)
+	if !p.Replace {
+		for k, v := range metadata {
+			result[k] = v
+		}
+	}
+	for _, k := range p.Remove {
+		delete(result, k)
+	}
+	for k, v := range p.Set {
+		result[k] = v
+	}
+	return result
+}
+
+// ValidateMetadata checks that every key can be used in a Selector and that
+// keys and values fit in the control service limits.
+func ValidateMetadata(metadata map[string]string) error {
+	for k, v := range metadata {
+		if err := validateKey(k); err != nil {
+			return fmt.Errorf("Invalid metadata: %s", err)
+		}
+		if len(k) > maxMetadataKeyLength {
+			return fmt.Errorf("Invalid metadata: key '%s' is longer than %d characters", k, maxMetadataKeyLength)
+		}
+		if len(v) > maxMetadataValueLength {
+			return fmt.Errorf("Invalid metadata: value of '%s' is longer than %d characters", k, maxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+/*
+UpdateDatasetMetadata applies the patch to the metadata of the given dataset
+and returns its updated configuration.
+
+The patch is applied over the metadata just read. When the control service
+supports configuration tags the update is made conditional to it, and retried
+over the new metadata if the configuration changed in between.
+*/
+func (c Client) UpdateDatasetMetadata(datasetID string, patch MetadataPatch) (*DatasetConfiguration, error) {
+	return c.UpdateDatasetMetadataContext(context.Background(), datasetID, patch)
+}
+
+// UpdateDatasetMetadataContext is like UpdateDatasetMetadata but the requests
+// are bound to ctx. If opts set a precondition, see IfConfigurationMatches, it
+// is used instead and the update is not retried.
+func (c Client) UpdateDatasetMetadataContext(ctx context.Context, datasetID string, patch MetadataPatch, opts ...WriteOption) (*DatasetConfiguration, error) {
+	if err := patch.Validate(); err != nil {
+		return nil, err
+	}
+
+	precondition := newWriteOptions(opts).ifMatches
+
+	var err error
+	for attempt := 0; attempt < metadataUpdateAttempts; attempt++ {
+		var configurations []DatasetConfiguration
+		var tag string
+		configurations, tag, err = c.listConfigurations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if precondition != "" {
+			tag = precondition
+		}
+
+		configuration := findConfiguration(configurations, datasetID)
+		if configuration == nil {
+			return nil, ErrDatasetNotFound
+		}
+
+		payload := struct {
+			Metadata map[string]string `json:"metadata"`
+		}{
+			Metadata: patch.Apply(configuration.Metadata),
+		}
+
+		var updated *DatasetConfiguration
+		updated, err = c.postConfiguration(ctx, datasetID, payload, IfConfigurationMatches(tag))
+		if err == nil {
+			return updated, nil
+		}
+		if precondition != "" || !errors.Is(err, ErrPreconditionFailed) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// findConfiguration returns the configuration, not deleted, of the given
+// dataset or nil if there is none.
+func findConfiguration(configurations []DatasetConfiguration, datasetID string) *DatasetConfiguration {
+	for i := range configurations {
+		if configurations[i].DatasetID == datasetID && !configurations[i].Deleted {
+			return &configurations[i]
+		}
+	}
+	return nil
+}
+
+// postConfiguration posts a change to the configuration of the given
+// dataset, returning the configuration after the change.
+func (c Client) postConfiguration(ctx context.Context, datasetID string, payload interface{}, opts ...WriteOption) (*DatasetConfiguration, error) {
+	url := c.getURL(fmt.Sprintf("configuration/datasets/%s", datasetID))
+	resp, err := c.post(ctx, url, payload, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, newAPIError(resp)
+	}
+
+	var configuration DatasetConfiguration
+	if err := json.NewDecoder(resp.Body).Decode(&configuration); err != nil {
+		return nil, err
+	}
+	return &configuration, nil
+}
@@ -0,0 +1,93 @@
+package flocker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataPatchApply(t *testing.T) {
+	assert := assert.New(t)
+	metadata := map[string]string{"name": "db", "owner": "alice", "protected": "true"}
+
+	merged := MetadataPatch{
+		Set:    map[string]string{"owner": "bob", "tenant": "blue"},
+		Remove: []string{"protected", "missing"},
+	}.Apply(metadata)
+	assert.Equal(map[string]string{"name": "db", "owner": "bob", "tenant": "blue"}, merged)
+	assert.Equal("alice", metadata["owner"], "the original metadata is untouched")
+
+	replaced := MetadataPatch{
+		Set:     map[string]string{"name": "db"},
+		Replace: true,
+	}.Apply(metadata)
+	assert.Equal(map[string]string{"name": "db"}, replaced)
+}
+
+func TestMetadataPatchValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(MetadataPatch{Set: map[string]string{"owner": "bob"}, Remove: []string{"tenant"}}.Validate())
+	assert.Error(MetadataPatch{Set: map[string]string{"owner": "bob"}, Remove: []string{"owner"}}.Validate())
+	assert.Error(MetadataPatch{Remove: []string{"owner"}, Replace: true}.Validate())
+	assert.Error(MetadataPatch{Set: map[string]string{"": "empty"}}.Validate())
+	assert.Error(MetadataPatch{Set: map[string]string{"with space": "x"}}.Validate())
+	assert.Error(MetadataPatch{Set: map[string]string{strings.Repeat("k", 257): "x"}}.Validate())
+	assert.Error(MetadataPatch{Set: map[string]string{"k": strings.Repeat("v", 257)}}.Validate())
+}
+
+func TestUpdateDatasetMetadata(t *testing.T) {
+	assert := assert.New(t)
+	var (
+		posts int
+		tag   = "tag1"
+	)
+
+	c, done := newHandlerTestClient(assert, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/version":
+			w.Write([]byte(`{"flocker": "1.15.0"}`))
+		case r.Method == "GET":
+			assert.Equal("/v1/configuration/datasets", r.URL.Path)
+			w.Header().Set(configurationTagHeader, tag)
+			w.Write([]byte(`[{"dataset_id": "d1", "primary": "p1", "metadata": {"name": "db", "owner": "alice"}}]`))
+		case r.Method == "POST":
+			assert.Equal("/v1/configuration/datasets/d1", r.URL.Path)
+			posts++
+			if posts == 1 {
+				// Someone else changed the configuration in between
+				tag = "tag2"
+			}
+			if r.Header.Get(ifConfigurationMatchesHeader) != tag {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+
+			var p map[string]map[string]string
+			assert.NoError(json.NewDecoder(r.Body).Decode(&p))
+			assert.Equal(map[string]string{"name": "db", "tenant": "blue"}, p["metadata"])
+			b, _ := json.Marshal(DatasetConfiguration{DatasetID: "d1", Primary: "p1", Metadata: p["metadata"]})
+			w.Write(b)
+		}
+	}))
+	defer done()
+
+	configuration, err := c.UpdateDatasetMetadata("d1", MetadataPatch{
+		Set:    map[string]string{"tenant": "blue"},
+		Remove: []string{"owner"},
+	})
+	assert.NoError(err)
+	assert.Equal(2, posts, "retried after the precondition failed")
+	assert.Equal(map[string]string{"name": "db", "tenant": "blue"}, configuration.Metadata)
+
+	_, err = c.UpdateDatasetMetadata("unknown", MetadataPatch{Set: map[string]string{"tenant": "blue"}})
+	assert.True(errors.Is(err, ErrNotFound))
+
+	_, err = c.UpdateDatasetMetadata("d1", MetadataPatch{Set: map[string]string{"": "blue"}})
+	assert.Error(err)
+	assert.Equal(2, posts, "invalid patches are not sent")
+}
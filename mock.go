@@ -0,0 +1,134 @@
+package flocker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+//go:generate go run gen_mock.go
+
+// ErrNotStubbed is returned by a MockClient method whose stub function is not
+// set, or which has no recorded call left to replay.
+var ErrNotStubbed = errors.New("Method not stubbed")
+
+// Call is a call made to a MockClient or a RecordingClient.
+type Call struct {
+	// Method is the name of the Clientable method called.
+	Method string
+	Args   []interface{}
+	// Results are the values returned, but the error.
+	Results []interface{}
+	Err     error
+}
+
+// callLog is the call history of a MockClient or a RecordingClient.
+type callLog struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (l *callLog) record(method string, args, results []interface{}, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, Call{Method: method, Args: args, Results: results, Err: err})
+}
+
+// Calls returns every call made so far, in order.
+func (l *callLog) Calls() []Call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Call{}, l.calls...)
+}
+
+// CallsTo returns the calls made so far to the given method, in order.
+func (l *callLog) CallsTo(method string) []Call {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var calls []Call
+	for _, c := range l.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the calls made so far.
+func (l *callLog) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = nil
+}
+
+func notStubbed(method string) error {
+	return fmt.Errorf("%w: %s", ErrNotStubbed, method)
+}
+
+// MockClient is defined in mock_generated.go, along with the methods of
+// MockClient and RecordingClient.
+var _ ExtendedClientable = &MockClient{}
+
+/*
+RecordingClient wraps a Clientable and records every call made through it,
+along with what it returned, see Calls. It is safe for concurrent use if the
+wrapped Clientable is.
+
+The methods of ExtendedClientable fail, and are recorded as failed, when the
+wrapped client does not implement it.
+
+Recorded calls can be replayed with Replay.
+*/
+type RecordingClient struct {
+	client Clientable
+	callLog
+}
+
+var _ ExtendedClientable = &RecordingClient{}
+
+// NewRecordingClient returns a RecordingClient wrapping client.
+func NewRecordingClient(client Clientable) *RecordingClient {
+	return &RecordingClient{client: client}
+}
+
+// extended returns the wrapped client as an ExtendedClientable, needed by
+// method.
+func (r *RecordingClient) extended(method string) (ExtendedClientable, error) {
+	c, ok := r.client.(ExtendedClientable)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement ExtendedClientable, needed by %s", r.client, method)
+	}
+	return c, nil
+}
+
+// Replay returns a MockClient answering with the results recorded so far.
+// Every call to a method gets the results of the next recorded call to the
+// same method, whatever the arguments, until there is none left.
+func (r *RecordingClient) Replay() *MockClient {
+	return ReplayCalls(r.Calls())
+}
+
+// ReplayCalls returns a MockClient answering with the results of the given
+// calls, see RecordingClient.Replay.
+func ReplayCalls(calls []Call) *MockClient {
+	return replayMock(&replayer{calls: append([]Call{}, calls...)})
+}
+
+// replayer hands the recorded calls out in order.
+type replayer struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// next returns the next recorded call to method.
+func (r *replayer) next(method string) (Call, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range r.calls {
+		if c.Method == method {
+			r.calls = append(r.calls[:i:i], r.calls[i+1:]...)
+			return c, nil
+		}
+	}
+	return Call{}, fmt.Errorf("%w: no recorded call to %s left", ErrNotStubbed, method)
+}
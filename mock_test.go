@@ -0,0 +1,121 @@
+package flocker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockClient(t *testing.T) {
+	assert := assert.New(t)
+
+	var c Clientable = &MockClient{
+		GetDatasetIDFunc: func(name string) (string, error) {
+			if name == "db" {
+				return "d1", nil
+			}
+			return "", ErrConfigurationNotFound
+		},
+	}
+
+	id, err := c.GetDatasetID("db")
+	assert.NoError(err)
+	assert.Equal("d1", id)
+
+	_, err = c.GetDatasetID("web")
+	assert.Equal(ErrConfigurationNotFound, err)
+
+	err = c.DeleteDataset("d1")
+	assert.True(errors.Is(err, ErrNotStubbed))
+	assert.Equal("Method not stubbed: DeleteDataset", err.Error())
+
+	m := c.(*MockClient)
+	assert.Equal([]Call{
+		{Method: "GetDatasetID", Args: []interface{}{"db"}, Results: []interface{}{"d1"}},
+		{Method: "GetDatasetID", Args: []interface{}{"web"}, Results: []interface{}{""}, Err: ErrConfigurationNotFound},
+		{Method: "DeleteDataset", Args: []interface{}{"d1"}, Results: []interface{}{}, Err: err},
+	}, m.Calls())
+	assert.Len(m.CallsTo("GetDatasetID"), 2)
+	assert.Empty(m.CallsTo("ListNodes"))
+
+	m.Reset()
+	assert.Empty(m.Calls())
+}
+
+func TestRecordingClient(t *testing.T) {
+	assert := assert.New(t)
+
+	state := &DatasetState{DatasetID: "d1", Primary: "p1"}
+	m := &MockClient{
+		MoveDatasetFunc: func(datasetID, newPrimary string, opts *MoveOptions) (*DatasetState, error) {
+			return state, nil
+		},
+		ReleaseLeaseFunc: func(datasetID string) error {
+			return ErrNotFound
+		},
+	}
+
+	r := NewRecordingClient(m)
+	s, err := r.MoveDataset("d1", "p1", nil)
+	assert.NoError(err)
+	assert.Equal(state, s)
+	assert.Equal(ErrNotFound, r.ReleaseLease("d1"))
+
+	assert.Equal([]Call{
+		{Method: "MoveDataset", Args: []interface{}{"d1", "p1", (*MoveOptions)(nil)}, Results: []interface{}{state}},
+		{Method: "ReleaseLease", Args: []interface{}{"d1"}, Results: []interface{}{}, Err: ErrNotFound},
+	}, r.Calls())
+	assert.Equal(m.Calls(), r.Calls())
+}
+
+func TestRecordingClientNotExtended(t *testing.T) {
+	assert := assert.New(t)
+
+	// Only the methods of Clientable are promoted
+	m := &MockClient{}
+	r := NewRecordingClient(struct{ Clientable }{m})
+
+	_, err := r.MoveDataset("d1", "p1", nil)
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "does not implement ExtendedClientable, needed by MoveDataset")
+	}
+	assert.Len(r.CallsTo("MoveDataset"), 1)
+	assert.Empty(m.Calls())
+}
+
+func TestReplay(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &MockClient{
+		GetDatasetIDFunc: func(name string) (string, error) {
+			return name + "-id", nil
+		},
+		ListNodesFunc: func() ([]NodeState, error) {
+			return nil, ErrTimeout
+		},
+	}
+	r := NewRecordingClient(m)
+	r.GetDatasetID("db")
+	r.ListNodes()
+	r.GetDatasetID("web")
+
+	replay := r.Replay()
+
+	// Calls to each method are replayed in order, whatever the arguments
+	_, err := replay.ListNodes()
+	assert.Equal(ErrTimeout, err)
+	id, err := replay.GetDatasetID("anything")
+	assert.NoError(err)
+	assert.Equal("db-id", id)
+	id, err = replay.GetDatasetID("anything")
+	assert.NoError(err)
+	assert.Equal("web-id", id)
+
+	_, err = replay.GetDatasetID("db")
+	assert.True(errors.Is(err, ErrNotStubbed))
+	_, err = replay.GetPrimaryUUID()
+	assert.True(errors.Is(err, ErrNotStubbed))
+
+	assert.Len(replay.Calls(), 5)
+}
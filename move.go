@@ -0,0 +1,119 @@
+package flocker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MoveOptions tunes MoveDataset, the zero value is fine.
+type MoveOptions struct {
+	// Timeout overrides the client's wait timeout when it is not zero.
+	Timeout time.Duration
+	// Progress, when set, is called every time the state of the dataset is
+	// polled.
+	Progress func(MoveProgress)
+	// RevertOnTimeout moves the dataset back to its previous primary in the
+	// configuration if it did not arrive in time.
+	RevertOnTimeout bool
+}
+
+// MoveProgress is what MoveDataset knows about a dataset still moving.
+type MoveProgress struct {
+	DatasetID     string
+	TargetPrimary string
+	// CurrentPrimary is the primary the dataset agents report, empty while
+	// the dataset is not attached anywhere.
+	CurrentPrimary string
+	Path           string
+	Elapsed        time.Duration
+}
+
+/*
+MoveDataset makes newPrimary the primary of the given dataset and waits until
+the dataset has actually arrived there, that is, the dataset agents report it
+on newPrimary with a path.
+
+If the dataset does not arrive in time an error matching ErrTimeout is
+returned, after moving the dataset back in the configuration if
+RevertOnTimeout is set.
+*/
+func (c Client) MoveDataset(datasetID, newPrimary string, opts *MoveOptions) (*DatasetState, error) {
+	return c.MoveDatasetContext(context.Background(), datasetID, newPrimary, opts)
+}
+
+// MoveDatasetContext is like MoveDataset but every request, as well as the
+// wait for the dataset to arrive, is bound to ctx.
+func (c Client) MoveDatasetContext(ctx context.Context, datasetID, newPrimary string, opts *MoveOptions) (*DatasetState, error) {
+	return c.moveDataset(ctx, datasetID, newPrimary, opts, nil)
+}
+
+// moveDataset moves the dataset, calling moved, when set, with the previous
+// primary once the configuration is changed.
+func (c Client) moveDataset(ctx context.Context, datasetID, newPrimary string, opts *MoveOptions, moved func(oldPrimary string)) (*DatasetState, error) {
+	if opts == nil {
+		opts = &MoveOptions{}
+	}
+	if opts.Timeout > 0 {
+		c.waitTimeout = opts.Timeout
+	}
+
+	configurations, err := c.ListDatasetConfigurationsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configuration := findConfiguration(configurations, datasetID)
+	if configuration == nil {
+		return nil, ErrDatasetNotFound
+	}
+	oldPrimary := configuration.Primary
+
+	if _, err := c.UpdatePrimaryForDatasetContext(ctx, newPrimary, datasetID); err != nil {
+		return nil, err
+	}
+	if moved != nil {
+		moved(oldPrimary)
+	}
+
+	start := c.clock.Now()
+	var s *DatasetState
+	err = c.waitFor(ctx, func() (bool, error) {
+		var errState error
+		s, errState = c.GetDatasetStateContext(ctx, datasetID)
+		if errState != nil && errState != ErrStateNotFound {
+			return false, errState
+		}
+
+		progress := MoveProgress{
+			DatasetID:     datasetID,
+			TargetPrimary: newPrimary,
+			Elapsed:       c.clock.Now().Sub(start),
+		}
+		if s != nil {
+			progress.CurrentPrimary = s.Primary
+			progress.Path = s.Path
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+
+		return s != nil && s.Primary == newPrimary && s.Path != "", nil
+	})
+
+	switch {
+	case err == nil:
+		return s, nil
+	case err == errWaitTimeout:
+		var strErrRevert string
+		if opts.RevertOnTimeout && oldPrimary != newPrimary {
+			if _, errRevert := c.UpdatePrimaryForDatasetContext(ctx, oldPrimary, datasetID); errRevert != nil {
+				strErrRevert = fmt.Sprintf(", moving the dataset back to %s failed with %s", oldPrimary, errRevert)
+			} else {
+				strErrRevert = fmt.Sprintf(", dataset moved back to %s", oldPrimary)
+			}
+		}
+		return nil, fmt.Errorf("%w during dataset move (datasetID %s): it did not arrive to %s%s", ErrTimeout, datasetID, newPrimary, strErrRevert)
+	default:
+		return nil, fmt.Errorf("Flocker API error during dataset move (datasetID %s): %w", datasetID, err)
+	}
+}
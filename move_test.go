@@ -0,0 +1,109 @@
+package flocker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// moveServer moves its only dataset to the configured primary after
+// arriveAfter polls of its state, never if it is zero.
+type moveServer struct {
+	configured  string
+	current     string
+	arriveAfter int
+	statePolls  int
+	moves       []string
+}
+
+func (s *moveServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v1/configuration/datasets":
+		w.Write([]byte(fmt.Sprintf(`[{"dataset_id": "d1", "primary": "%s"}]`, s.configured)))
+	case r.Method == "GET" && r.URL.Path == "/v1/state/datasets":
+		s.statePolls++
+		if s.arriveAfter > 0 && s.statePolls >= s.arriveAfter {
+			s.current = s.configured
+		}
+		if s.current == "" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(fmt.Sprintf(`[{"dataset_id": "d1", "primary": "%s", "path": "/flocker/d1"}]`, s.current)))
+	case r.Method == "POST":
+		var p map[string]string
+		json.NewDecoder(r.Body).Decode(&p)
+		s.configured = p["primary"]
+		s.moves = append(s.moves, p["primary"])
+		// Detached while moving
+		s.current = ""
+		w.Write([]byte(fmt.Sprintf(`{"dataset_id": "d1", "primary": "%s"}`, s.configured)))
+	}
+}
+
+func TestMoveDataset(t *testing.T) {
+	assert := assert.New(t)
+	s := &moveServer{configured: "node1", current: "node1", arriveAfter: 3}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	var progress []MoveProgress
+	state, err := c.MoveDataset("d1", "node2", &MoveOptions{
+		Progress: func(p MoveProgress) { progress = append(progress, p) },
+	})
+	assert.NoError(err)
+	assert.Equal("node2", state.Primary)
+	assert.Equal("/flocker/d1", state.Path)
+	assert.Equal([]string{"node2"}, s.moves)
+
+	if assert.Equal(3, len(progress)) {
+		assert.Equal(MoveProgress{DatasetID: "d1", TargetPrimary: "node2"}, progress[0])
+		assert.Equal(time.Second, progress[1].Elapsed)
+		assert.Equal("node2", progress[2].CurrentPrimary)
+	}
+}
+
+func TestMoveDatasetTimesOut(t *testing.T) {
+	assert := assert.New(t)
+	s := &moveServer{configured: "node1", current: "node1"}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	_, err := c.MoveDataset("d1", "node2", &MoveOptions{Timeout: 10 * time.Second})
+	assert.True(errors.Is(err, ErrTimeout))
+	assert.Equal(11, s.statePolls)
+	assert.Equal([]string{"node2"}, s.moves, "not moved back")
+}
+
+func TestMoveDatasetRevertsOnTimeout(t *testing.T) {
+	assert := assert.New(t)
+	s := &moveServer{configured: "node1", current: "node1"}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	_, err := c.MoveDataset("d1", "node2", &MoveOptions{RevertOnTimeout: true})
+	assert.True(errors.Is(err, ErrTimeout))
+	assert.True(strings.HasSuffix(err.Error(), "dataset moved back to node1"), err.Error())
+	assert.Equal([]string{"node2", "node1"}, s.moves)
+}
+
+func TestMoveDatasetUnknown(t *testing.T) {
+	assert := assert.New(t)
+	s := &moveServer{configured: "node1", current: "node1"}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	_, err := c.MoveDataset("unknown", "node2", nil)
+	assert.True(errors.Is(err, ErrNotFound))
+	assert.Empty(s.moves)
+}
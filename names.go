@@ -0,0 +1,69 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrAmbiguousName is matched by errors.Is when a name is used by more than
+// one dataset, the error is then an *AmbiguousNameError.
+var ErrAmbiguousName = errors.New("Ambiguous dataset name")
+
+// AmbiguousNameError is returned when looking a dataset up by a name that more
+// than one dataset, not deleted, has.
+type AmbiguousNameError struct {
+	Name string
+	// DatasetIDs are the IDs of every dataset with the name, sorted.
+	DatasetIDs []string
+}
+
+func (e *AmbiguousNameError) Error() string {
+	return fmt.Sprintf("Dataset name '%s' is used by %d datasets: %s", e.Name, len(e.DatasetIDs), strings.Join(e.DatasetIDs, ", "))
+}
+
+// Is lets errors.Is match an AmbiguousNameError against ErrAmbiguousName.
+func (e *AmbiguousNameError) Is(target error) bool {
+	return target == ErrAmbiguousName
+}
+
+// FindDuplicateNames returns the names used by more than one dataset, not
+// deleted, along with the sorted IDs of those datasets. It is empty when every
+// name is unique.
+func (c Client) FindDuplicateNames() (map[string][]string, error) {
+	return c.FindDuplicateNamesContext(context.Background())
+}
+
+// FindDuplicateNamesContext is like FindDuplicateNames but the request is
+// bound to ctx.
+func (c Client) FindDuplicateNamesContext(ctx context.Context) (map[string][]string, error) {
+	configurations, err := c.ListDatasetConfigurationsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := map[string][]string{}
+	for name, ids := range datasetIDsByName(configurations) {
+		if len(ids) > 1 {
+			duplicates[name] = ids
+		}
+	}
+	return duplicates, nil
+}
+
+// datasetIDsByName groups the IDs of the datasets, not deleted, by name.
+// Datasets without a name are left out.
+func datasetIDsByName(configurations []DatasetConfiguration) map[string][]string {
+	names := map[string][]string{}
+	for _, c := range configurations {
+		if name := c.Metadata["name"]; name != "" && !c.Deleted {
+			names[name] = append(names[name], c.DatasetID)
+		}
+	}
+	for _, ids := range names {
+		sort.Strings(ids)
+	}
+	return names
+}
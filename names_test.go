@@ -0,0 +1,78 @@
+package flocker
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const duplicatedConfigurations = `[
+	{"dataset_id": "d3", "primary": "p1", "metadata": {"name": "db"}},
+	{"dataset_id": "d1", "primary": "p1", "metadata": {"name": "db"}},
+	{"dataset_id": "d2", "primary": "p1", "metadata": {"name": "db"}, "deleted": true},
+	{"dataset_id": "d4", "primary": "p1", "metadata": {"name": "web"}},
+	{"dataset_id": "d5", "primary": "p1", "metadata": {"name": "cache"}, "deleted": true},
+	{"dataset_id": "d6", "primary": "p1", "metadata": {"name": "cache"}},
+	{"dataset_id": "d7", "primary": "p1"},
+	{"dataset_id": "d8", "primary": "p1"}
+]`
+
+// namesHandler answers the listing of the dataset configurations with
+// duplicatedConfigurations.
+func namesHandler(assert *assert.Assertions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/v1/configuration/datasets", r.URL.Path)
+		w.Write([]byte(duplicatedConfigurations))
+	}
+}
+
+func TestGetDatasetIDAmbiguous(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, namesHandler(assert))
+	defer done()
+
+	_, err := c.GetDatasetID("db")
+	assert.True(errors.Is(err, ErrAmbiguousName))
+	var errAmbiguous *AmbiguousNameError
+	assert.True(errors.As(err, &errAmbiguous))
+	assert.Equal("db", errAmbiguous.Name)
+	assert.Equal([]string{"d1", "d3"}, errAmbiguous.DatasetIDs)
+	assert.Equal("Dataset name 'db' is used by 2 datasets: d1, d3", err.Error())
+	assert.False(errors.Is(err, ErrNotFound))
+
+	// Deleted datasets do not count
+	id, err := c.GetDatasetID("cache")
+	assert.NoError(err)
+	assert.Equal("d6", id)
+
+	_, err = c.EnsureDataset("db", nil)
+	assert.True(errors.Is(err, ErrAmbiguousName))
+}
+
+func TestFindIDInConfigurationsPayloadSkipsDeleted(t *testing.T) {
+	assert := assert.New(t)
+	c := Client{}
+
+	id, err := c.findIDInConfigurationsPayload(ioutil.NopCloser(bytes.NewBufferString(duplicatedConfigurations)), "cache")
+	assert.NoError(err)
+	assert.Equal("d6", id)
+
+	_, err = c.findIDInConfigurationsPayload(ioutil.NopCloser(bytes.NewBufferString(duplicatedConfigurations)), "db")
+	assert.True(errors.Is(err, ErrAmbiguousName))
+}
+
+func TestFindDuplicateNames(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, namesHandler(assert))
+	defer done()
+
+	duplicates, err := c.FindDuplicateNames()
+	assert.NoError(err)
+	assert.Equal(map[string][]string{"db": {"d1", "d3"}}, duplicates)
+}
@@ -0,0 +1,249 @@
+package flocker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// rollbackTimeout bounds rolling back a cancelled operation, which cannot
+// use the context of the operation.
+const rollbackTimeout = 30 * time.Second
+
+// OperationStatus says where an Operation is.
+type OperationStatus int
+
+const (
+	// OperationRunning is the status of an operation not done yet.
+	OperationRunning OperationStatus = iota
+	// OperationSucceeded is the status of an operation done without error.
+	OperationSucceeded
+	// OperationFailed is the status of an operation done with an error.
+	OperationFailed
+	// OperationCancelled is the status of an operation stopped by Cancel, or
+	// by cancelling the context it is bound to.
+	OperationCancelled
+)
+
+func (s OperationStatus) String() string {
+	switch s {
+	case OperationRunning:
+		return "running"
+	case OperationSucceeded:
+		return "succeeded"
+	case OperationFailed:
+		return "failed"
+	case OperationCancelled:
+		return "cancelled"
+	}
+	return fmt.Sprintf("OperationStatus(%d)", int(s))
+}
+
+/*
+Operation is a handle on a call running in the background, such as one
+started by CreateDatasetAsync. Its result is collected with Wait, or once Done
+is closed.
+
+An Operation is safe for concurrent use.
+*/
+type Operation struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	status    OperationStatus
+	cancelled bool
+	rollback  bool
+	undo      []func(context.Context) error
+	state     *DatasetState
+	err       error
+}
+
+// startOperation runs fn in the background, in an operation bound to ctx.
+func startOperation(ctx context.Context, fn func(context.Context, *Operation) (*DatasetState, error)) *Operation {
+	ctx, cancel := context.WithCancel(ctx)
+	o := &Operation{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go o.run(ctx, fn)
+	return o
+}
+
+func (o *Operation) run(ctx context.Context, fn func(context.Context, *Operation) (*DatasetState, error)) {
+	defer close(o.done)
+	defer o.cancel()
+
+	s, err := fn(ctx, o)
+
+	o.mu.Lock()
+	cancelled, rollback, undo := o.cancelled, o.rollback, o.undo
+	o.mu.Unlock()
+
+	status := OperationSucceeded
+	switch {
+	case err == nil:
+	case cancelled || errors.Is(err, context.Canceled):
+		// Cancelling the parent context cancels the operation too
+		status = OperationCancelled
+		if cancelled && rollback {
+			if errUndo := runUndo(undo); errUndo != nil {
+				err = errors.Join(err, fmt.Errorf("Rolling back the cancelled operation failed: %w", errUndo))
+			}
+		}
+	default:
+		status = OperationFailed
+	}
+
+	o.mu.Lock()
+	o.status, o.state, o.err = status, s, err
+	o.mu.Unlock()
+}
+
+// runUndo runs the undo steps of an operation, last one first.
+func runUndo(undo []func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rollbackTimeout)
+	defer cancel()
+
+	var errs []error
+	for i := len(undo) - 1; i >= 0; i-- {
+		if err := undo[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// onRollback records how to undo a change already made, so that it can be
+// rolled back if the operation is cancelled.
+func (o *Operation) onRollback(undo func(context.Context) error) {
+	o.mu.Lock()
+	o.undo = append(o.undo, undo)
+	o.mu.Unlock()
+}
+
+// Done is closed when the operation is done.
+func (o *Operation) Done() <-chan struct{} {
+	return o.done
+}
+
+// Wait waits until the operation is done and returns its result, the state of
+// the dataset for everything but deletions. If ctx is done first ctx.Err() is
+// returned and the operation keeps running.
+func (o *Operation) Wait(ctx context.Context) (*DatasetState, error) {
+	select {
+	case <-o.done:
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return o.state, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Status says whether the operation is still running and, if not, how it
+// ended.
+func (o *Operation) Status() OperationStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.status
+}
+
+/*
+Cancel stops the operation, it does nothing once the operation is done. Wait
+then returns an error wrapping context.Canceled.
+
+With rollback the changes already made are undone: a dataset being created is
+deleted and a dataset being moved is moved back to its previous primary.
+Resizes and deletions cannot be undone, they are only stopped.
+*/
+func (o *Operation) Cancel(rollback bool) {
+	o.mu.Lock()
+	if o.status == OperationRunning {
+		o.cancelled = true
+		o.rollback = o.rollback || rollback
+	}
+	o.mu.Unlock()
+	o.cancel()
+}
+
+// CreateDatasetAsync is like CreateDataset but returns at once with an
+// Operation handle on the creation.
+func (c *Client) CreateDatasetAsync(options *CreateDatasetOptions) *Operation {
+	return c.CreateDatasetAsyncContext(context.Background(), options)
+}
+
+// CreateDatasetAsyncContext is like CreateDatasetAsync but the whole
+// operation is bound to ctx.
+func (c *Client) CreateDatasetAsyncContext(ctx context.Context, options *CreateDatasetOptions) *Operation {
+	// The creation fills options in, the caller keeps its own
+	opts := CreateDatasetOptions{}
+	if options != nil {
+		opts = *options
+	}
+	return startOperation(ctx, func(ctx context.Context, o *Operation) (*DatasetState, error) {
+		datasetID, err := c.postDataset(ctx, &opts)
+		if err != nil {
+			return nil, err
+		}
+		o.onRollback(func(ctx context.Context) error {
+			err := c.DeleteDatasetContext(ctx, datasetID)
+			if errors.Is(err, ErrNotFound) {
+				return nil
+			}
+			return err
+		})
+		// As with CreateDatasetContext, the dataset is only deleted on
+		// cancellation when Cancel asks for a rollback
+		return c.waitCreated(ctx, datasetID)
+	})
+}
+
+// MoveDatasetAsync is like MoveDataset but returns at once with an Operation
+// handle on the move.
+func (c Client) MoveDatasetAsync(datasetID, newPrimary string, opts *MoveOptions) *Operation {
+	return c.MoveDatasetAsyncContext(context.Background(), datasetID, newPrimary, opts)
+}
+
+// MoveDatasetAsyncContext is like MoveDatasetAsync but the whole operation is
+// bound to ctx.
+func (c Client) MoveDatasetAsyncContext(ctx context.Context, datasetID, newPrimary string, opts *MoveOptions) *Operation {
+	return startOperation(ctx, func(ctx context.Context, o *Operation) (*DatasetState, error) {
+		return c.moveDataset(ctx, datasetID, newPrimary, opts, func(oldPrimary string) {
+			o.onRollback(func(ctx context.Context) error {
+				_, err := c.UpdatePrimaryForDatasetContext(ctx, oldPrimary, datasetID)
+				return err
+			})
+		})
+	})
+}
+
+// ResizeDatasetAsync is like ResizeDataset but returns at once with an
+// Operation handle on the resize.
+func (c Client) ResizeDatasetAsync(datasetID string, newSize Size) *Operation {
+	return c.ResizeDatasetAsyncContext(context.Background(), datasetID, newSize)
+}
+
+// ResizeDatasetAsyncContext is like ResizeDatasetAsync but the whole
+// operation is bound to ctx.
+func (c Client) ResizeDatasetAsyncContext(ctx context.Context, datasetID string, newSize Size) *Operation {
+	return startOperation(ctx, func(ctx context.Context, _ *Operation) (*DatasetState, error) {
+		return c.ResizeDatasetContext(ctx, datasetID, newSize)
+	})
+}
+
+// DeleteDatasetAsync is like DeleteDatasetAndWait but returns at once with an
+// Operation handle on the deletion.
+func (c Client) DeleteDatasetAsync(datasetID string, opts *DeleteOptions) *Operation {
+	return c.DeleteDatasetAsyncContext(context.Background(), datasetID, opts)
+}
+
+// DeleteDatasetAsyncContext is like DeleteDatasetAsync but the whole
+// operation is bound to ctx.
+func (c Client) DeleteDatasetAsyncContext(ctx context.Context, datasetID string, opts *DeleteOptions) *Operation {
+	return startOperation(ctx, func(ctx context.Context, _ *Operation) (*DatasetState, error) {
+		return nil, c.DeleteDatasetAndWaitContext(ctx, datasetID, opts)
+	})
+}
@@ -0,0 +1,212 @@
+package flocker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// operationServer has a dataset d1 on p1 which never converges to anything,
+// it records the configuration changes it receives.
+type operationServer struct {
+	mu      sync.Mutex
+	changes []string
+	polling chan struct{}
+	status  int
+}
+
+func (s *operationServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status != 0 {
+		w.WriteHeader(s.status)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/v1/state/nodes":
+		w.Write([]byte(`[{"host": "127.0.0.1", "uuid": "p1"}]`))
+	case r.URL.Path == "/v1/state/datasets":
+		// Polling starts once the change is made
+		select {
+		case s.polling <- struct{}{}:
+		default:
+		}
+		w.Write([]byte(`[]`))
+	case r.Method == "GET" && r.URL.Path == "/v1/configuration/datasets":
+		w.Write([]byte(`[{"dataset_id": "d1", "primary": "p1"}]`))
+	case r.Method == "DELETE":
+		s.changes = append(s.changes, "delete "+r.URL.Path)
+		w.Write([]byte(`{"dataset_id": "d1", "primary": "p1", "deleted": true}`))
+	case r.Method == "POST":
+		var p configurationPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		if r.URL.Path == "/v1/configuration/datasets" {
+			s.changes = append(s.changes, "create")
+		} else {
+			s.changes = append(s.changes, "move "+p.Primary)
+		}
+		w.Write([]byte(`{"dataset_id": "d1", "primary": "p1"}`))
+	}
+}
+
+func (s *operationServer) recorded() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.changes...)
+}
+
+func TestCreateDatasetAsyncCancelRollback(t *testing.T) {
+	assert := assert.New(t)
+	s := &operationServer{polling: make(chan struct{}, 1)}
+
+	c, done := newHandlerTestClient(assert, s, WithPollInterval(time.Millisecond))
+	defer done()
+
+	op := c.CreateDatasetAsync(&CreateDatasetOptions{})
+	assert.Equal(OperationRunning, op.Status())
+
+	<-s.polling
+	op.Cancel(true)
+	<-op.Done()
+
+	state, err := op.Wait(context.Background())
+	assert.Nil(state)
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal(OperationCancelled, op.Status())
+	assert.Equal([]string{"create", "delete /v1/configuration/datasets/d1"}, s.recorded())
+}
+
+func TestCreateDatasetAsyncCancelWithoutRollback(t *testing.T) {
+	assert := assert.New(t)
+	s := &operationServer{polling: make(chan struct{}, 1)}
+
+	c, done := newHandlerTestClient(assert, s, WithPollInterval(time.Millisecond))
+	defer done()
+
+	op := c.CreateDatasetAsync(&CreateDatasetOptions{})
+	<-s.polling
+	op.Cancel(false)
+
+	_, err := op.Wait(context.Background())
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal(OperationCancelled, op.Status())
+	assert.Equal([]string{"create"}, s.recorded())
+}
+
+func TestMoveDatasetAsyncCancelRollback(t *testing.T) {
+	assert := assert.New(t)
+	s := &operationServer{polling: make(chan struct{}, 1)}
+
+	c, done := newHandlerTestClient(assert, s, WithPollInterval(time.Millisecond))
+	defer done()
+
+	op := c.MoveDatasetAsync("d1", "p2", nil)
+	<-s.polling
+	op.Cancel(true)
+
+	_, err := op.Wait(context.Background())
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal(OperationCancelled, op.Status())
+	assert.Equal([]string{"move p2", "move p1"}, s.recorded())
+}
+
+func TestOperationParentContextCancelled(t *testing.T) {
+	assert := assert.New(t)
+	s := &operationServer{polling: make(chan struct{}, 1)}
+
+	c, done := newHandlerTestClient(assert, s, WithPollInterval(time.Millisecond))
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	op := c.MoveDatasetAsyncContext(ctx, "d1", "p2", nil)
+	<-s.polling
+	cancel()
+
+	_, err := op.Wait(context.Background())
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal(OperationCancelled, op.Status())
+	assert.Equal([]string{"move p2"}, s.recorded(), "no rollback without Cancel(true)")
+}
+
+func TestCreateDatasetAsyncParentContextCancelled(t *testing.T) {
+	assert := assert.New(t)
+	s := &operationServer{polling: make(chan struct{}, 1)}
+
+	c, done := newHandlerTestClient(assert, s, WithPollInterval(time.Millisecond))
+	defer done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	op := c.CreateDatasetAsyncContext(ctx, &CreateDatasetOptions{})
+	<-s.polling
+	cancel()
+
+	_, err := op.Wait(context.Background())
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Equal(OperationCancelled, op.Status())
+	assert.Equal([]string{"create"}, s.recorded(), "the dataset is left in place, as with CreateDatasetContext")
+}
+
+func TestOperationFailed(t *testing.T) {
+	assert := assert.New(t)
+	s := &operationServer{status: http.StatusInternalServerError}
+
+	c, done := newHandlerTestClient(assert, s, WithPollInterval(time.Millisecond))
+	defer done()
+
+	op := c.DeleteDatasetAsync("d1", nil)
+	_, err := op.Wait(context.Background())
+	assert.Error(err)
+	assert.Equal(OperationFailed, op.Status())
+
+	// Cancelling once done changes nothing
+	op.Cancel(true)
+	assert.Equal(OperationFailed, op.Status())
+	assert.Empty(s.recorded())
+}
+
+func TestOperationSucceeded(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/v1/configuration/datasets":
+			w.Write([]byte(`[{"dataset_id": "d1", "primary": "p1", "maximum_size": 1073741824}]`))
+		case r.Method == "POST":
+			w.Write([]byte(`{"dataset_id": "d1", "primary": "p1", "maximum_size": 2147483648}`))
+		case r.URL.Path == "/v1/state/datasets":
+			w.Write([]byte(`[{"dataset_id": "d1", "primary": "p1", "maximum_size": 2147483648, "path": "/flocker/d1"}]`))
+		}
+	}))
+	defer done()
+
+	op := c.ResizeDatasetAsync("d1", 2*GiB)
+	state, err := op.Wait(context.Background())
+	assert.NoError(err)
+	assert.Equal("d1", state.DatasetID)
+	assert.Equal(OperationSucceeded, op.Status())
+}
+
+func TestOperationWaitContext(t *testing.T) {
+	assert := assert.New(t)
+	s := &operationServer{polling: make(chan struct{}, 1)}
+
+	c, done := newHandlerTestClient(assert, s, WithPollInterval(time.Millisecond))
+	defer done()
+
+	op := c.CreateDatasetAsync(&CreateDatasetOptions{})
+	defer op.Cancel(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := op.Wait(ctx)
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.Equal(OperationRunning, op.Status())
+}
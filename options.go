@@ -0,0 +1,115 @@
+package flocker
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// A volume can take a long time to be available, if we don't want
+	// Kubernetes to wait forever we need to stop trying after some time, that
+	// time is defined here
+	defaultWaitTimeout  = 2 * time.Minute
+	defaultPollInterval = 5 * time.Second
+)
+
+// Option configures a Client, see NewClient.
+type Option func(*Client)
+
+// WithWaitTimeout sets how long the client waits for the control service to
+// converge (e.g. for a created dataset to show up in its state) before giving
+// up.
+func WithWaitTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.waitTimeout = d
+	}
+}
+
+// WithPollInterval makes the client poll the control service every d while
+// waiting for it to converge. It is a shortcut for WithBackoff(ConstantBackoff(d)).
+func WithPollInterval(d time.Duration) Option {
+	return WithBackoff(ConstantBackoff(d))
+}
+
+// WithBackoff sets the strategy used to space the polls while waiting for the
+// control service to converge.
+func WithBackoff(b Backoff) Option {
+	return func(c *Client) {
+		c.backoff = b
+	}
+}
+
+// WithClock replaces the clock used to measure timeouts and poll intervals,
+// mostly useful to make tests deterministic.
+func WithClock(clock Clock) Option {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// WithHTTPClient makes the client send its requests with hc, which is then
+// in charge of TLS: the certificates given to NewClient are not loaded. It is
+// mostly useful to talk to a fake control service, see the flockertest
+// package.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.Client = hc
+	}
+}
+
+// Clock is the source of time used by a Client while waiting.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Backoff decides how long to wait between consecutive attempts.
+type Backoff interface {
+	// Delay returns how long to wait after the given attempt, attempts are
+	// numbered from 1.
+	Delay(attempt int) time.Duration
+}
+
+// ConstantBackoff waits the same amount of time after every attempt.
+type ConstantBackoff time.Duration
+
+// Delay implements Backoff.
+func (b ConstantBackoff) Delay(attempt int) time.Duration {
+	return time.Duration(b)
+}
+
+// ExponentialBackoff waits Initial after the first attempt and multiplies the
+// delay by Multiplier after every other one, never waiting more than Max (when
+// Max is not zero). When Jitter is set, every delay is randomly moved by up to
+// that fraction of itself, e.g. 0.2 gives delays between 80% and 120%.
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// Delay implements Backoff.
+func (b ExponentialBackoff) Delay(attempt int) time.Duration {
+	d := float64(b.Initial)
+	for i := 1; i < attempt; i++ {
+		d *= b.Multiplier
+		if b.Max > 0 && d >= float64(b.Max) {
+			break
+		}
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,69 @@
+package flocker
+
+import (
+	"context"
+	"fmt"
+)
+
+/*
+ResizeDataset grows the given dataset to newSize bytes and waits until the
+dataset agents report the new size, returning its state.
+
+newSize is rounded as the client's RoundingPolicy says, see WithSizeRounding.
+It must then be a multiple of 1024 and larger than the current size, datasets
+cannot shrink.
+*/
+func (c Client) ResizeDataset(datasetID string, newSize Size) (*DatasetState, error) {
+	return c.ResizeDatasetContext(context.Background(), datasetID, newSize)
+}
+
+// ResizeDatasetContext is like ResizeDataset but every request, as well as
+// the wait for the new size, is bound to ctx.
+func (c Client) ResizeDatasetContext(ctx context.Context, datasetID string, newSize Size) (*DatasetState, error) {
+	newSize = c.roundSize(newSize)
+	if newSize <= 0 || newSize%1024 != 0 {
+		return nil, fmt.Errorf("Invalid size %s for dataset %s: it must be a positive multiple of 1024", newSize, datasetID)
+	}
+
+	configurations, err := c.ListDatasetConfigurationsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	configuration := findConfiguration(configurations, datasetID)
+	if configuration == nil {
+		return nil, ErrDatasetNotFound
+	}
+	if configuration.MaximumSize != 0 && newSize <= configuration.MaximumSize {
+		return nil, fmt.Errorf("Invalid size %s for dataset %s: it must be larger than the current size %s", newSize, datasetID, configuration.MaximumSize)
+	}
+
+	payload := struct {
+		MaximumSize Size `json:"maximum_size"`
+	}{
+		MaximumSize: newSize,
+	}
+	if _, err := c.postConfiguration(ctx, datasetID, payload); err != nil {
+		return nil, err
+	}
+
+	var s *DatasetState
+	err = c.waitFor(ctx, func() (bool, error) {
+		var errState error
+		s, errState = c.GetDatasetStateContext(ctx, datasetID)
+		if errState == ErrStateNotFound {
+			return false, nil
+		} else if errState != nil {
+			return false, errState
+		}
+		return s.MaximumSize == newSize, nil
+	})
+
+	switch {
+	case err == nil:
+		return s, nil
+	case err == errWaitTimeout:
+		return nil, fmt.Errorf("%w during dataset resize (datasetID %s): size is not %s yet", ErrTimeout, datasetID, newSize)
+	default:
+		return nil, fmt.Errorf("Flocker API error during dataset resize (datasetID %s): %w", datasetID, err)
+	}
+}
@@ -0,0 +1,102 @@
+package flocker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resizeServer grows its only dataset after statePolls polls of its state.
+type resizeServer struct {
+	size       int64
+	newSize    int64
+	statePolls int
+	growAfter  int
+	posted     bool
+}
+
+func (s *resizeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/v1/configuration/datasets":
+		size := s.size
+		if s.posted {
+			size = s.newSize
+		}
+		w.Write([]byte(fmt.Sprintf(`[{"dataset_id": "d1", "primary": "p1", "maximum_size": %d}]`, size)))
+	case r.Method == "GET" && r.URL.Path == "/v1/state/datasets":
+		s.statePolls++
+		size := s.size
+		if s.posted && s.growAfter > 0 && s.statePolls >= s.growAfter {
+			size = s.newSize
+		}
+		w.Write([]byte(fmt.Sprintf(`[{"dataset_id": "d1", "primary": "p1", "maximum_size": %d, "path": "/flocker/d1"}]`, size)))
+	case r.Method == "POST":
+		var p map[string]int64
+		json.NewDecoder(r.Body).Decode(&p)
+		s.newSize = p["maximum_size"]
+		s.posted = true
+		w.Write([]byte(fmt.Sprintf(`{"dataset_id": "d1", "primary": "p1", "maximum_size": %d}`, s.newSize)))
+	}
+}
+
+func TestResizeDataset(t *testing.T) {
+	assert := assert.New(t)
+	s := &resizeServer{size: 1024 * 1024, growAfter: 3}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	state, err := c.ResizeDataset("d1", 2*1024*1024)
+	assert.NoError(err)
+	assert.Equal(2*MiB, state.MaximumSize)
+	assert.Equal(int64(2*1024*1024), s.newSize)
+	assert.Equal(3, s.statePolls)
+}
+
+func TestResizeDatasetValidatesSize(t *testing.T) {
+	assert := assert.New(t)
+	s := &resizeServer{size: 1024 * 1024, growAfter: 1}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute), WithSizeRounding(nil))
+	defer done()
+
+	_, err := c.ResizeDataset("d1", 2*1024*1024+1)
+	assert.Error(err, "not a multiple of 1024")
+	_, err = c.ResizeDataset("d1", 1024)
+	assert.Error(err, "smaller than the current size")
+	_, err = c.ResizeDataset("d1", 1024*1024)
+	assert.Error(err, "same as the current size")
+	assert.False(s.posted)
+
+	_, err = c.ResizeDataset("unknown", 2*1024*1024)
+	assert.True(errors.Is(err, ErrNotFound))
+}
+
+func TestResizeDatasetTimesOut(t *testing.T) {
+	assert := assert.New(t)
+	s := &resizeServer{size: 1024 * 1024}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute))
+	defer done()
+
+	_, err := c.ResizeDataset("d1", 2*1024*1024)
+	assert.True(errors.Is(err, ErrTimeout))
+	assert.Equal(61, s.statePolls)
+}
+
+func TestResizeDatasetRoundsSize(t *testing.T) {
+	assert := assert.New(t)
+	s := &resizeServer{size: 1024 * 1024, growAfter: 1}
+
+	c, done := newHandlerTestClient(assert, s, WithClock(&fakeClock{}), WithPollInterval(time.Second), WithWaitTimeout(time.Minute), WithSizeRounding(RoundUpTo(GiB)))
+	defer done()
+
+	_, err := c.ResizeDataset("d1", 1500*MiB)
+	assert.NoError(err)
+	assert.Equal(int64(2*GiB), s.newSize)
+}
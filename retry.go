@@ -0,0 +1,123 @@
+package flocker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryPolicy is the RetryPolicy used by clients created with
+// NewClient unless WithRetryPolicy says otherwise.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	Backoff: ExponentialBackoff{
+		Initial:    200 * time.Millisecond,
+		Max:        2 * time.Second,
+		Multiplier: 2,
+		Jitter:     0.2,
+	},
+}
+
+// RetryPolicy controls how the client retries requests to the control
+// service that failed for transient reasons.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made for a request, values
+	// lower than 2 disable retries.
+	MaxAttempts int
+	// Backoff spaces the attempts.
+	Backoff Backoff
+	// Retryable decides if a failed attempt may be retried, when it is nil
+	// DefaultRetryable is used. resp is nil when err is not.
+	Retryable func(req *http.Request, resp *http.Response, err error) bool
+}
+
+// WithRetryPolicy sets the RetryPolicy used for every request to the control
+// service.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+/*
+DefaultRetryable retries:
+
+- any request that could not be sent because the connection to the control
+service could not be established.
+- idempotent requests (GET, HEAD, OPTIONS, PUT and DELETE) that failed with a
+transport error or with a 429, 502, 503 or 504 status code.
+
+A POST that may have reached the control service is never retried, as it
+could create or move a dataset twice. Neither is a request a replaying
+Cassette has no interaction for, it would not have one the next time.
+*/
+func DefaultRetryable(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, ErrNotRecorded) {
+			return false
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		return isIdempotent(req.Method)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return isIdempotent(req.Method)
+	}
+	return false
+}
+
+// isIdempotent says whether the HTTP method can be safely repeated.
+func isIdempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// retryable says whether the failed attempt can be retried.
+func (p RetryPolicy) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if err == nil && resp.StatusCode < 300 {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(req, resp, err)
+	}
+	return DefaultRetryable(req, resp, err)
+}
+
+// delay returns how long to wait after the given failed attempt.
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	if p.Backoff == nil {
+		return 0
+	}
+	return p.Backoff.Delay(attempt)
+}
+
+// attemptsExhausted builds the error returned when the last attempt of a
+// request failed, closing its response if any.
+func attemptsExhausted(attempts int, req *http.Request, resp *http.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s %s failed after %d attempts: %w", req.Method, req.URL.Path, attempts, err)
+	}
+	defer resp.Body.Close()
+
+	apiErr := newAPIError(resp)
+	apiErr.Attempts = attempts
+	return apiErr
+}
+
+// discard drains and closes the body of a response that is thrown away, so
+// the connection can be reused.
+func discard(resp *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+	resp.Body.Close()
+}
@@ -0,0 +1,125 @@
+package flocker
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingTransport fails the first failures round trips with err and then
+// delegates to the default transport.
+type failingTransport struct {
+	err      error
+	failures int
+	calls    int
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, f.err
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func unavailableHandler(unavailable int, calls *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		if *calls <= unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"description": "Try again later."}`))
+			return
+		}
+		w.Write([]byte(`[{"host": "127.0.0.1", "uuid": "uuid1"}]`))
+	}
+}
+
+func TestRetryTransientStatusCode(t *testing.T) {
+	assert := assert.New(t)
+	var calls int
+
+	c, done := newHandlerTestClient(assert, unavailableHandler(2, &calls), WithClock(&fakeClock{}))
+	defer done()
+
+	nodes, err := c.ListNodes()
+	assert.NoError(err)
+	assert.Equal(3, calls)
+	assert.Equal(1, len(nodes))
+}
+
+func TestRetryReportsAttempts(t *testing.T) {
+	assert := assert.New(t)
+	var calls int
+
+	c, done := newHandlerTestClient(assert, unavailableHandler(100, &calls),
+		WithClock(&fakeClock{}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 4, Backoff: ConstantBackoff(time.Second)}),
+	)
+	defer done()
+
+	_, err := c.ListNodes()
+	assert.Equal(4, calls)
+
+	var apiErr *APIError
+	if assert.True(errors.As(err, &apiErr)) {
+		assert.Equal(4, apiErr.Attempts)
+		assert.Equal(http.StatusServiceUnavailable, apiErr.StatusCode)
+		assert.Equal("Flocker API error: GET /v1/state/nodes returned 503: Try again later. (after 4 attempts)", err.Error())
+	}
+}
+
+func TestRetryDoesNotRepeatPost(t *testing.T) {
+	assert := assert.New(t)
+	var calls int
+
+	c, done := newHandlerTestClient(assert, unavailableHandler(100, &calls), WithClock(&fakeClock{}))
+	defer done()
+
+	_, err := c.UpdatePrimaryForDataset("uuid1", "datasetID")
+	assert.Error(err)
+	assert.Equal(1, calls)
+}
+
+func TestRetryTransportErrors(t *testing.T) {
+	assert := assert.New(t)
+	var calls int
+
+	c, done := newHandlerTestClient(assert, unavailableHandler(0, &calls), WithClock(&fakeClock{}))
+	defer done()
+	transport := &failingTransport{err: errors.New("connection reset by peer"), failures: 2}
+	c.Client = &http.Client{Transport: transport}
+
+	_, err := c.ListNodes()
+	assert.NoError(err)
+	assert.Equal(3, transport.calls)
+
+	transport = &failingTransport{err: errors.New("connection reset by peer"), failures: 100}
+	c.Client = &http.Client{Transport: transport}
+
+	_, err = c.ListNodes()
+	assert.Equal(3, transport.calls)
+	if assert.Error(err) {
+		assert.True(strings.Contains(err.Error(), "failed after 3 attempts"), err.Error())
+	}
+
+	transport = &failingTransport{err: errors.New("connection reset by peer"), failures: 100}
+	c.Client = &http.Client{Transport: transport}
+
+	_, err = c.UpdatePrimaryForDataset("uuid1", "datasetID")
+	assert.Error(err)
+	assert.Equal(1, transport.calls, "a POST that may have been sent is not retried")
+}
+
+func TestExponentialBackoffJitter(t *testing.T) {
+	assert := assert.New(t)
+
+	b := ExponentialBackoff{Initial: time.Second, Multiplier: 2, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := b.Delay(2)
+		assert.True(d >= time.Second && d <= 3*time.Second, d.String())
+	}
+}
@@ -0,0 +1,219 @@
+package flocker
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SelectorOperator is how a Requirement checks its key.
+type SelectorOperator int
+
+const (
+	// SelectorEquals requires the key to have the first of the values.
+	SelectorEquals SelectorOperator = iota
+	// SelectorNotEquals requires the key not to have the first of the values,
+	// or to be missing.
+	SelectorNotEquals
+	// SelectorIn requires the key to have one of the values.
+	SelectorIn
+	// SelectorNotIn requires the key to have none of the values, or to be
+	// missing.
+	SelectorNotIn
+	// SelectorExists requires the key to be there, whatever its value.
+	SelectorExists
+	// SelectorNotExists requires the key to be missing.
+	SelectorNotExists
+)
+
+var (
+	selectorKeyRegexp = regexp.MustCompile(`^[^\s=!(),]+$`)
+	selectorSetRegexp = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// Requirement is a single condition of a Selector on the metadata key Key.
+type Requirement struct {
+	Key      string
+	Operator SelectorOperator
+	// Values are unused by SelectorExists and SelectorNotExists.
+	Values []string
+}
+
+// Matches says whether the metadata satisfies the requirement.
+func (r Requirement) Matches(metadata map[string]string) bool {
+	value, ok := metadata[r.Key]
+	switch r.Operator {
+	case SelectorEquals:
+		return ok && value == r.value()
+	case SelectorNotEquals:
+		return !ok || value != r.value()
+	case SelectorIn:
+		return ok && contains(r.Values, value)
+	case SelectorNotIn:
+		return !ok || !contains(r.Values, value)
+	case SelectorExists:
+		return ok
+	case SelectorNotExists:
+		return !ok
+	}
+	return false
+}
+
+// value returns the value compared by SelectorEquals and SelectorNotEquals.
+func (r Requirement) value() string {
+	if len(r.Values) == 0 {
+		return ""
+	}
+	return r.Values[0]
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case SelectorEquals:
+		return r.Key + "=" + r.value()
+	case SelectorNotEquals:
+		return r.Key + "!=" + r.value()
+	case SelectorIn:
+		return r.Key + " in (" + strings.Join(r.Values, ",") + ")"
+	case SelectorNotIn:
+		return r.Key + " notin (" + strings.Join(r.Values, ",") + ")"
+	case SelectorNotExists:
+		return "!" + r.Key
+	}
+	return r.Key
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector matches datasets by their metadata, see ParseSelector. It can also
+// be built from its requirements, all of them must hold for a dataset to
+// match.
+type Selector []Requirement
+
+/*
+ParseSelector parses a comma separated list of requirements on the metadata
+of datasets, all of them must hold for a dataset to match:
+
+	owner=alice         owner is alice (== is also accepted)
+	owner!=alice        owner is not alice, or there is no owner
+	tenant in (a,b)     tenant is a or b
+	tenant notin (a,b)  tenant is neither a nor b, or there is no tenant
+	protected           there is a protected key, whatever its value
+	!protected          there is no protected key
+
+The empty selector matches every dataset.
+*/
+func ParseSelector(s string) (Selector, error) {
+	var selector Selector
+	for _, term := range splitSelector(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("Invalid selector '%s': empty requirement", s)
+		}
+
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid selector '%s': %s", s, err)
+		}
+		selector = append(selector, r)
+	}
+	return selector, nil
+}
+
+// splitSelector splits s on the commas that are not inside parentheses.
+func splitSelector(s string) []string {
+	var (
+		terms []string
+		depth int
+		start int
+	)
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	if m := selectorSetRegexp.FindStringSubmatch(term); m != nil {
+		r := Requirement{Key: m[1], Operator: SelectorIn}
+		if m[2] == "notin" {
+			r.Operator = SelectorNotIn
+		}
+		for _, v := range strings.Split(m[3], ",") {
+			r.Values = append(r.Values, strings.TrimSpace(v))
+		}
+		sort.Strings(r.Values)
+		return r, validateKey(r.Key)
+	}
+
+	for _, op := range []struct {
+		token    string
+		operator SelectorOperator
+	}{
+		{"!=", SelectorNotEquals},
+		{"==", SelectorEquals},
+		{"=", SelectorEquals},
+	} {
+		if i := strings.Index(term, op.token); i >= 0 {
+			r := Requirement{
+				Key:      strings.TrimSpace(term[:i]),
+				Operator: op.operator,
+				Values:   []string{strings.TrimSpace(term[i+len(op.token):])},
+			}
+			return r, validateKey(r.Key)
+		}
+	}
+
+	if strings.HasPrefix(term, "!") {
+		r := Requirement{Key: strings.TrimSpace(term[1:]), Operator: SelectorNotExists}
+		return r, validateKey(r.Key)
+	}
+	return Requirement{Key: term, Operator: SelectorExists}, validateKey(term)
+}
+
+func validateKey(key string) error {
+	if !selectorKeyRegexp.MatchString(key) {
+		return fmt.Errorf("invalid key '%s'", key)
+	}
+	return nil
+}
+
+// Matches says whether the metadata satisfies every requirement of the
+// selector.
+func (s Selector) Matches(metadata map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(metadata) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Selector) String() string {
+	terms := make([]string, len(s))
+	for i, r := range s {
+		terms[i] = r.String()
+	}
+	return strings.Join(terms, ",")
+}
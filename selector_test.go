@@ -0,0 +1,80 @@
+package flocker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	metadata := map[string]string{
+		"name":   "db",
+		"owner":  "alice",
+		"tenant": "blue",
+	}
+
+	for _, test := range []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"owner=alice", true},
+		{"owner==alice", true},
+		{"owner = bob", false},
+		{"owner!=bob", true},
+		{"missing!=bob", true},
+		{"owner!=alice", false},
+		{"tenant in (red, blue)", true},
+		{"tenant in (red)", false},
+		{"missing in (red)", false},
+		{"tenant notin (red,green)", true},
+		{"tenant notin (blue)", false},
+		{"missing notin (blue)", true},
+		{"owner", true},
+		{"missing", false},
+		{"!missing", true},
+		{"!owner", false},
+		{"owner=alice,tenant in (blue,red),!protected", true},
+		{"owner=alice,protected", false},
+	} {
+		selector, err := ParseSelector(test.selector)
+		if assert.NoError(t, err, test.selector) {
+			assert.Equal(t, test.matches, selector.Matches(metadata), test.selector)
+		}
+	}
+}
+
+func TestParseSelectorErrors(t *testing.T) {
+	for _, s := range []string{
+		"owner=alice,",
+		",owner",
+		"=alice",
+		"!",
+		"tenant in (a,b",
+		"two words",
+	} {
+		_, err := ParseSelector(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSelectorString(t *testing.T) {
+	selector, err := ParseSelector("owner == alice, tenant in (red,blue), !protected, app")
+	assert.NoError(t, err)
+	assert.Equal(t, "owner=alice,tenant in (blue,red),!protected,app", selector.String())
+}
+
+func TestSelectorFromRequirements(t *testing.T) {
+	selector := Selector{
+		{Key: "owner", Operator: SelectorEquals, Values: []string{"alice"}},
+		{Key: "tenant", Operator: SelectorIn, Values: []string{"blue", "red"}},
+		{Key: "protected", Operator: SelectorNotExists},
+	}
+	parsed, err := ParseSelector("owner=alice, tenant in (red,blue), !protected")
+	assert.NoError(t, err)
+	assert.Equal(t, parsed, selector)
+
+	assert.True(t, selector.Matches(map[string]string{"owner": "alice", "tenant": "red"}))
+	assert.False(t, selector.Matches(map[string]string{"owner": "alice", "tenant": "red", "protected": "yes"}))
+	assert.True(t, Requirement{Key: "owner", Operator: SelectorNotEquals}.Matches(map[string]string{"owner": "alice"}))
+}
@@ -0,0 +1,160 @@
+package flocker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Size is an amount of bytes, such as the maximum size of a dataset.
+type Size int64
+
+// Decimal and binary size units.
+const (
+	Byte Size = 1
+
+	KB Size = 1000 * Byte
+	MB Size = 1000 * KB
+	GB Size = 1000 * MB
+	TB Size = 1000 * GB
+	PB Size = 1000 * TB
+
+	KiB Size = 1024 * Byte
+	MiB Size = 1024 * KiB
+	GiB Size = 1024 * MiB
+	TiB Size = 1024 * GiB
+	PiB Size = 1024 * TiB
+)
+
+var (
+	sizeRegexp = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([A-Za-z]*)$`)
+
+	sizeUnits = map[string]Size{
+		"": Byte, "b": Byte,
+		"k": KB, "kb": KB, "ki": KiB, "kib": KiB,
+		"m": MB, "mb": MB, "mi": MiB, "mib": MiB,
+		"g": GB, "gb": GB, "gi": GiB, "gib": GiB,
+		"t": TB, "tb": TB, "ti": TiB, "tib": TiB,
+		"p": PB, "pb": PB, "pi": PiB, "pib": PiB,
+	}
+
+	// sizeNames lists the units String picks from, the larger first.
+	sizeNames = []struct {
+		unit Size
+		name string
+	}{
+		{PiB, "PiB"}, {PB, "PB"},
+		{TiB, "TiB"}, {TB, "TB"},
+		{GiB, "GiB"}, {GB, "GB"},
+		{MiB, "MiB"}, {MB, "MB"},
+		{KiB, "KiB"}, {KB, "KB"},
+	}
+)
+
+/*
+ParseSize parses a size such as "10GiB", "500M" or "1.5T". Units are case
+insensitive, with or without the final B: K, M, G, T and P are powers of 1000
+while Ki, Mi, Gi, Ti and Pi are powers of 1024. A number without unit is a
+number of bytes.
+
+Negative sizes and sizes that are not a whole number of bytes are rejected.
+*/
+func ParseSize(s string) (Size, error) {
+	m := sizeRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("Invalid size '%s'", s)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(m[2])]
+	if !ok {
+		return 0, fmt.Errorf("Invalid size '%s': unknown unit '%s'", s, m[2])
+	}
+
+	n, ok := new(big.Rat).SetString(m[1])
+	if !ok {
+		return 0, fmt.Errorf("Invalid size '%s'", s)
+	}
+	n.Mul(n, new(big.Rat).SetInt64(int64(unit)))
+	if !n.IsInt() {
+		return 0, fmt.Errorf("Invalid size '%s': it is not a whole number of bytes", s)
+	}
+	if !n.Num().IsInt64() {
+		return 0, fmt.Errorf("Invalid size '%s': it is too large", s)
+	}
+	return Size(n.Num().Int64()), nil
+}
+
+// String formats the size with the largest unit it is a whole number of, e.g.
+// 10GiB, 500MB or 1023B.
+func (s Size) String() string {
+	if s != 0 {
+		for _, n := range sizeNames {
+			if s%n.unit == 0 {
+				return fmt.Sprintf("%d%s", s/n.unit, n.name)
+			}
+		}
+	}
+	return fmt.Sprintf("%dB", int64(s))
+}
+
+// Set parses the size, along with String it makes Size a flag.Value.
+func (s *Size) Set(value string) error {
+	parsed, err := ParseSize(value)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// UnmarshalJSON accepts a number of bytes, as the control service sends, or a
+// string such as "10GiB". null is a zero size.
+func (s *Size) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		*s = 0
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var str string
+		if err := json.Unmarshal(b, &str); err != nil {
+			return err
+		}
+		return s.Set(str)
+	}
+
+	var n int64
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("Invalid size %s: %s", b, err)
+	}
+	if n < 0 {
+		return fmt.Errorf("Invalid size %d: it is negative", n)
+	}
+	*s = Size(n)
+	return nil
+}
+
+// RoundingPolicy adjusts a size to what the storage backend can allocate.
+type RoundingPolicy func(Size) Size
+
+// RoundUpTo rounds sizes up to a multiple of unit, e.g. RoundUpTo(GiB) for
+// backends such as EBS that allocate whole GiB.
+func RoundUpTo(unit Size) RoundingPolicy {
+	return func(s Size) Size {
+		if unit <= 0 || s%unit == 0 {
+			return s
+		}
+		return (s/unit + 1) * unit
+	}
+}
+
+// WithSizeRounding sets how the sizes given to CreateDataset and
+// ResizeDataset are rounded before being sent, by default they are rounded up
+// to a multiple of 1KiB.
+func WithSizeRounding(policy RoundingPolicy) Option {
+	return func(c *Client) {
+		c.rounding = policy
+	}
+}
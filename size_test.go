@@ -0,0 +1,102 @@
+package flocker
+
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	for _, test := range []struct {
+		in  string
+		out Size
+	}{
+		{"0", 0},
+		{"1024", 1024},
+		{"512B", 512},
+		{"10GiB", 10 * GiB},
+		{"10gib", 10 * GiB},
+		{"10Gi", 10 * GiB},
+		{"500M", 500 * MB},
+		{"500MB", 500 * MB},
+		{"1T", TB},
+		{"1.5KiB", 1536},
+		{" 2 MiB ", 2 * MiB},
+		{"8PiB", 8 * PiB},
+	} {
+		size, err := ParseSize(test.in)
+		if assert.NoError(t, err, test.in) {
+			assert.Equal(t, test.out, size, test.in)
+		}
+	}
+}
+
+func TestParseSizeErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"-1GiB",
+		"GiB",
+		"10XB",
+		"0.5B",
+		"1.0000001KiB",
+		"10000000PiB",
+		"1e3",
+	} {
+		_, err := ParseSize(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestSizeString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("0B", Size(0).String())
+	assert.Equal("1023B", Size(1023).String())
+	assert.Equal("10GiB", (10 * GiB).String())
+	assert.Equal("500MB", (500 * MB).String())
+	assert.Equal("1536KiB", (1536 * KiB).String())
+	assert.Equal("100GiB", defaultVolumeSize.String())
+}
+
+func TestSizeJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	var v struct {
+		Number Size `json:"number"`
+		String Size `json:"string"`
+		Null   Size `json:"null"`
+	}
+	assert.NoError(json.Unmarshal([]byte(`{"number": 1024, "string": "1GiB", "null": null}`), &v))
+	assert.Equal(KiB, v.Number)
+	assert.Equal(GiB, v.String)
+	assert.Equal(Size(0), v.Null)
+
+	assert.Error(json.Unmarshal([]byte(`{"number": -1}`), &v))
+	assert.Error(json.Unmarshal([]byte(`{"string": "lots"}`), &v))
+
+	b, err := json.Marshal(CreateDatasetOptions{MaximumSize: 10 * GiB})
+	assert.NoError(err)
+	assert.Equal(`{"primary":"","maximum_size":10737418240}`, string(b))
+}
+
+func TestSizeFlag(t *testing.T) {
+	assert := assert.New(t)
+
+	var size Size
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&size, "size", "")
+	assert.NoError(fs.Parse([]string{"-size", "20GiB"}))
+	assert.Equal(20*GiB, size)
+}
+
+func TestRoundUpTo(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(GiB, RoundUpTo(GiB)(1))
+	assert.Equal(GiB, RoundUpTo(GiB)(GiB))
+	assert.Equal(2*GiB, RoundUpTo(GiB)(GiB+1))
+	assert.Equal(Size(2048), RoundUpTo(KiB)(1025))
+	assert.Equal(Size(1025), RoundUpTo(0)(1025))
+}
@@ -0,0 +1,81 @@
+package flocker
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// uuid is a parsed RFC 4122 UUID.
+type uuid [16]byte
+
+func parseUUID(s string) (uuid, error) {
+	var u uuid
+	h := strings.Replace(s, "-", "", -1)
+	if len(h) != 32 || len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("Invalid UUID '%s'", s)
+	}
+	if _, err := hex.Decode(u[:], []byte(h)); err != nil {
+		return u, fmt.Errorf("Invalid UUID '%s'", s)
+	}
+	return u, nil
+}
+
+func (u uuid) String() string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// uuidV5 returns the name based UUID (version 5, SHA-1) of name in namespace.
+func uuidV5(namespace uuid, name string) uuid {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+
+	var u uuid
+	copy(u[:], h.Sum(nil))
+	u[6] = (u[6] & 0x0f) | 0x50
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+// DatasetIDForName returns the dataset ID derived from name in namespace,
+// which must be a UUID. It is always the same for the same namespace and
+// name, see WithDeterministicIDs.
+func DatasetIDForName(namespace, name string) (string, error) {
+	ns, err := parseUUID(namespace)
+	if err != nil {
+		return "", err
+	}
+	return uuidV5(ns, name).String(), nil
+}
+
+/*
+WithDeterministicIDs makes the client derive the ID of the datasets it
+creates from their metadata name and namespace, a UUID, see
+DatasetIDForName. Retrying a creation after a crash then finds the dataset
+already exists instead of creating a duplicate, and looking a dataset up by
+name is looking it up by ID.
+
+Flocker keeps deleted datasets in its configuration, so in this mode the name
+of a deleted dataset cannot be used again.
+*/
+func WithDeterministicIDs(namespace string) Option {
+	return func(c *Client) {
+		c.idNamespace = namespace
+	}
+}
+
+// datasetIDForName returns the ID derived from name if the client has
+// deterministic IDs, or an empty string otherwise.
+func (c Client) datasetIDForName(name string) string {
+	if c.idNamespace == "" || name == "" {
+		return ""
+	}
+	id, err := DatasetIDForName(c.idNamespace, name)
+	if err != nil {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,85 @@
+package flocker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNamespace = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+func TestDatasetIDForName(t *testing.T) {
+	assert := assert.New(t)
+
+	// The RFC 4122 DNS namespace, checked against Python's uuid.uuid5
+	id, err := DatasetIDForName(testNamespace, "python.org")
+	assert.NoError(err)
+	assert.Equal("886313e1-3b8a-5372-9b90-0c9aee199e5d", id)
+
+	other, err := DatasetIDForName(testNamespace, "python.com")
+	assert.NoError(err)
+	assert.NotEqual(id, other)
+
+	for _, namespace := range []string{"", "nope", "6ba7b810x9dad-11d1-80b4-00c04fd430c8", "6ba7b810-9dad-11d1-80b4-00c04fd430cz"} {
+		_, err := DatasetIDForName(namespace, "python.org")
+		assert.Error(err, namespace)
+	}
+}
+
+func TestCreateDatasetDeterministicID(t *testing.T) {
+	assert := assert.New(t)
+
+	expectedID, err := DatasetIDForName(testNamespace, "db")
+	assert.NoError(err)
+
+	var posted CreateDatasetOptions
+	c, done := newHandlerTestClient(assert, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/state/nodes":
+			w.Write([]byte(`[{"host": "127.0.0.1", "uuid": "p1"}]`))
+		case r.Method == "POST":
+			assert.NoError(json.NewDecoder(r.Body).Decode(&posted))
+			w.WriteHeader(http.StatusConflict)
+		}
+	}), WithDeterministicIDs(testNamespace))
+	defer done()
+
+	_, err = c.CreateDataset(&CreateDatasetOptions{Metadata: map[string]string{"name": "db"}})
+	assert.Equal(ErrVolumeAlreadyExists, err)
+	assert.Equal(expectedID, posted.DatasetID)
+
+	// An explicit ID is kept
+	_, err = c.CreateDataset(&CreateDatasetOptions{DatasetID: "d1", Metadata: map[string]string{"name": "db"}})
+	assert.Equal(ErrVolumeAlreadyExists, err)
+	assert.Equal("d1", posted.DatasetID)
+}
+
+func TestGetDatasetIDDeterministic(t *testing.T) {
+	assert := assert.New(t)
+
+	id, err := DatasetIDForName(testNamespace, "db")
+	assert.NoError(err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A dataset created with the same name by someone else comes first
+		w.Write([]byte(`[
+			{"dataset_id": "other", "primary": "p1", "metadata": {"name": "db"}},
+			{"dataset_id": "` + id + `", "primary": "p1", "metadata": {"name": "db"}}
+		]`))
+	})
+
+	c, done := newHandlerTestClient(assert, handler, WithDeterministicIDs(testNamespace))
+	defer done()
+	datasetID, err := c.GetDatasetID("db")
+	assert.NoError(err)
+	assert.Equal(id, datasetID)
+
+	// Without the derived ID the lookup falls back to the name
+	c, done = newHandlerTestClient(assert, handler, WithDeterministicIDs("00000000-0000-0000-0000-000000000000"))
+	defer done()
+	_, err = c.GetDatasetID("db")
+	assert.True(errors.Is(err, ErrAmbiguousName))
+}
@@ -0,0 +1,186 @@
+package flocker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// ErrUnsupported is matched by errors.Is when the control service is too old
+// for the requested operation.
+var ErrUnsupported = errors.New("Not supported by the control service")
+
+// Capability is an optional feature of the control service API.
+type Capability string
+
+const (
+	// CapabilityLeases is the /configuration/leases API.
+	CapabilityLeases Capability = "leases"
+	// CapabilityConfigurationTags is the X-Configuration-Tag and
+	// X-If-Configuration-Matches headers.
+	CapabilityConfigurationTags Capability = "configuration-tags"
+	// CapabilityStorageProfiles is the profile metadata on datasets.
+	CapabilityStorageProfiles Capability = "storage-profiles"
+)
+
+// capabilityVersions is the first Flocker release shipping each capability.
+var capabilityVersions = map[Capability]Version{
+	CapabilityLeases:            {Major: 1, Minor: 3},
+	CapabilityConfigurationTags: {Major: 1, Minor: 4},
+	CapabilityStorageProfiles:   {Major: 1, Minor: 5},
+}
+
+var versionRegexp = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Version is a Flocker release.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Raw is the version as reported by the control service, e.g. 1.15.0 or
+	// 1.4.0+dev1.
+	Raw string
+}
+
+// ParseVersion parses a Flocker version such as 1.15.0, ignoring anything
+// after the patch number.
+func ParseVersion(s string) (Version, error) {
+	m := versionRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("Invalid Flocker version '%s'", s)
+	}
+
+	v := Version{Raw: s}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	if v.Raw != "" {
+		return v.Raw
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast says whether v is the same release as o or a later one.
+func (v Version) AtLeast(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major > o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor > o.Minor
+	}
+	return v.Patch >= o.Patch
+}
+
+// Supports says whether the release has the given capability.
+func (v Version) Supports(capability Capability) bool {
+	min, ok := capabilityVersions[capability]
+	return ok && v.AtLeast(min)
+}
+
+// Capabilities returns every capability known by this package that the
+// release has.
+func (v Version) Capabilities() map[Capability]bool {
+	caps := make(map[Capability]bool)
+	for capability := range capabilityVersions {
+		if v.Supports(capability) {
+			caps[capability] = true
+		}
+	}
+	return caps
+}
+
+// UnsupportedError is returned when an operation needs a capability the
+// control service does not have.
+type UnsupportedError struct {
+	Capability Capability
+	Version    Version
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("Flocker %s does not support %s, it needs %s or later", e.Version, e.Capability, capabilityVersions[e.Capability])
+}
+
+// Is lets errors.Is match an UnsupportedError against ErrUnsupported.
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// versionCache keeps the version of the control service once discovered.
+type versionCache struct {
+	mu      sync.Mutex
+	version *Version
+}
+
+// GetVersion asks the control service for its Flocker version.
+func (c Client) GetVersion() (*Version, error) {
+	return c.GetVersionContext(context.Background())
+}
+
+// GetVersionContext is like GetVersion but the request is bound to ctx.
+func (c Client) GetVersionContext(ctx context.Context) (*Version, error) {
+	resp, err := c.get(ctx, c.getURL("version"))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, newAPIError(resp)
+	}
+
+	var payload struct {
+		Flocker string `json:"flocker"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	v, err := ParseVersion(payload.Flocker)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.versions != nil {
+		c.versions.mu.Lock()
+		c.versions.version = &v
+		c.versions.mu.Unlock()
+	}
+	return &v, nil
+}
+
+// serviceVersion returns the version of the control service, asking for it
+// only the first time.
+func (c Client) serviceVersion(ctx context.Context) (*Version, error) {
+	if c.versions != nil {
+		c.versions.mu.Lock()
+		v := c.versions.version
+		c.versions.mu.Unlock()
+		if v != nil {
+			return v, nil
+		}
+	}
+	return c.GetVersionContext(ctx)
+}
+
+// require fails with an *UnsupportedError if the control service does not
+// have the capability.
+func (c Client) require(ctx context.Context, capability Capability) error {
+	v, err := c.serviceVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if !v.Supports(capability) {
+		return &UnsupportedError{Capability: capability, Version: *v}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package flocker
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	v, err := ParseVersion("1.15.0")
+	assert.NoError(err)
+	assert.Equal(Version{Major: 1, Minor: 15, Patch: 0, Raw: "1.15.0"}, v)
+
+	v, err = ParseVersion("1.4.1+dev2.gabcdef")
+	assert.NoError(err)
+	assert.Equal(1, v.Major)
+	assert.Equal(4, v.Minor)
+	assert.Equal(1, v.Patch)
+	assert.Equal("1.4.1+dev2.gabcdef", v.String())
+
+	v, err = ParseVersion("2.0")
+	assert.NoError(err)
+	assert.Equal(Version{Major: 2, Raw: "2.0"}, v)
+
+	_, err = ParseVersion("unknown")
+	assert.Error(err)
+}
+
+func TestVersionCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	old := Version{Major: 1, Minor: 0, Patch: 3}
+	assert.False(old.Supports(CapabilityLeases))
+	assert.Empty(old.Capabilities())
+
+	v := Version{Major: 1, Minor: 4}
+	assert.True(v.Supports(CapabilityLeases))
+	assert.True(v.Supports(CapabilityConfigurationTags))
+	assert.False(v.Supports(CapabilityStorageProfiles))
+	assert.False(v.Supports(Capability("unknown")))
+
+	assert.Equal(map[Capability]bool{
+		CapabilityLeases:            true,
+		CapabilityConfigurationTags: true,
+		CapabilityStorageProfiles:   true,
+	}, Version{Major: 1, Minor: 15}.Capabilities())
+}
+
+func TestGetVersionIsCheckedOnce(t *testing.T) {
+	assert := assert.New(t)
+	var versionCalls int
+
+	c, done := newHandlerTestClient(assert, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/version":
+			versionCalls++
+			w.Write([]byte(`{"flocker": "1.15.0"}`))
+		case "/v1/configuration/leases":
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer done()
+
+	v, err := c.GetVersion()
+	assert.NoError(err)
+	assert.Equal("1.15.0", v.String())
+
+	_, err = c.ListLeases()
+	assert.NoError(err)
+	_, err = c.ListLeases()
+	assert.NoError(err)
+	assert.Equal(1, versionCalls)
+}
+
+func TestLeasesUnsupportedByOldControlService(t *testing.T) {
+	assert := assert.New(t)
+
+	c, done := newHandlerTestClient(assert, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/v1/version", r.URL.Path)
+		w.Write([]byte(`{"flocker": "1.0.1"}`))
+	}))
+	defer done()
+
+	_, err := c.AcquireLease("datasetID", "node1", time.Minute)
+	assert.True(errors.Is(err, ErrUnsupported))
+	assert.Equal("Flocker 1.0.1 does not support leases, it needs 1.3.0 or later", err.Error())
+
+	var unsupported *UnsupportedError
+	if assert.True(errors.As(err, &unsupported)) {
+		assert.Equal(CapabilityLeases, unsupported.Capability)
+	}
+}